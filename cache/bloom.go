@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	bloomPrefix                   = "bloom:"
+	defaultBloomCapacity          = 1000000
+	defaultBloomFalsePositiveRate = 0.01
+	// maxBloomBits is the size limit of a Redis string, 512MB.
+	maxBloomBits = 1 << 32
+	// bloomBatchSize is the number of items sent in one pipeline.
+	bloomBatchSize = 1000
+)
+
+type BloomOptions struct {
+	// Capacity is the number of items the filter is sized for, it defaults
+	// to a million. The false positive rate grows past it.
+	Capacity uint64
+	// FalsePositiveRate is the probability for Exists to report an item
+	// that was never added, it defaults to 1%.
+	FalsePositiveRate float64
+}
+
+// BloomFilter is a probabilistic set stored in a Redis bitmap. Exists never
+// misses an added item but may report one that wasn't added, so a negative
+// answer can skip a lookup in the source of truth.
+type BloomFilter struct {
+	redis  *Redis
+	key    string
+	bits   uint64
+	hashes int
+}
+
+// BloomSource streams every item of a rebuilt filter to add.
+type BloomSource func(ctx context.Context, add func(items ...string) error) error
+
+// NewBloomFilter returns the filter stored under name. Filters sharing a
+// name must use the same options, the bitmap is only meaningful with the
+// size and number of hashes it was built with.
+func (r *Redis) NewBloomFilter(name string, options BloomOptions) *BloomFilter {
+	if options.Capacity == 0 {
+		options.Capacity = defaultBloomCapacity
+	}
+	if options.FalsePositiveRate <= 0 || options.FalsePositiveRate >= 1 {
+		options.FalsePositiveRate = defaultBloomFalsePositiveRate
+	}
+	bits, hashes := bloomSize(options.Capacity, options.FalsePositiveRate)
+	return &BloomFilter{
+		redis:  r,
+		key:    r.key(bloomPrefix + name),
+		bits:   bits,
+		hashes: hashes,
+	}
+}
+
+// Add adds items to the filter.
+func (b *BloomFilter) Add(ctx context.Context, items ...string) error {
+	if err := b.add(ctx, b.key, items); err != nil {
+		return newError("bloom add", ErrStore, err, b.key)
+	}
+	return nil
+}
+
+// Exists reports whether item may have been added to the filter.
+func (b *BloomFilter) Exists(ctx context.Context, item string) (bool, error) {
+	exists, err := b.ExistsMany(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	return exists[0], nil
+}
+
+// ExistsMany reports for every item whether it may have been added, in the
+// order of items.
+func (b *BloomFilter) ExistsMany(ctx context.Context, items ...string) ([]bool, error) {
+	exists := make([]bool, 0, len(items))
+	for start := 0; start < len(items); start += bloomBatchSize {
+		end := start + bloomBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		batch := items[start:end]
+		cmds := make([]*redisClient.IntCmd, 0, len(batch)*b.hashes)
+		_, err := b.redis.client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+			for _, item := range batch {
+				for _, offset := range b.offsets(item) {
+					cmds = append(cmds, pipe.GetBit(ctx, b.key, int64(offset)))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, newError("bloom exists", ErrRetrieve, err, b.key)
+		}
+
+		for i := range batch {
+			found := true
+			for _, cmd := range cmds[i*b.hashes : (i+1)*b.hashes] {
+				if cmd.Val() == 0 {
+					found = false
+					break
+				}
+			}
+			exists = append(exists, found)
+		}
+	}
+	return exists, nil
+}
+
+// Rebuild replaces the filter with one holding the items streamed by
+// source, for example after rows were deleted. The new filter is built
+// aside and swapped in atomically, items added meanwhile are lost unless
+// source returns them.
+func (b *BloomFilter) Rebuild(ctx context.Context, source BloomSource) error {
+	// The hash tag keeps the temporary key in the slot of the filter so it
+	// can be renamed on a cluster.
+	temporary := "{" + b.key + "}:rebuild"
+	client := b.redis.client
+	if err := client.Del(ctx, temporary).Err(); err != nil {
+		return newError("bloom rebuild", ErrStore, err, b.key)
+	}
+
+	var added bool
+	err := source(ctx, func(items ...string) error {
+		if len(items) > 0 {
+			added = true
+		}
+		return b.add(ctx, temporary, items)
+	})
+	if err != nil {
+		_ = client.Del(ctx, temporary).Err()
+		return newError("bloom rebuild", ErrStore, err, b.key)
+	}
+
+	if added {
+		err = client.Rename(ctx, temporary, b.key).Err()
+	} else {
+		err = client.Del(ctx, b.key).Err()
+	}
+	if err != nil {
+		return newError("bloom rebuild", ErrStore, err, b.key)
+	}
+	return nil
+}
+
+func (b *BloomFilter) add(ctx context.Context, key string, items []string) error {
+	for start := 0; start < len(items); start += bloomBatchSize {
+		end := start + bloomBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		_, err := b.redis.client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+			for _, item := range items[start:end] {
+				for _, offset := range b.offsets(item) {
+					pipe.SetBit(ctx, key, int64(offset), 1)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offsets returns the bits of item, derived from two 64 bit hashes as
+// described by Kirsch and Mitzenmacher.
+func (b *BloomFilter) offsets(item string) []uint64 {
+	hash := fnv.New128a()
+	hash.Write([]byte(item))
+	sum := hash.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:])
+
+	offsets := make([]uint64, b.hashes)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % b.bits
+	}
+	return offsets
+}
+
+// bloomSize returns the number of bits and hashes of a filter holding
+// capacity items with the false positive rate p.
+func bloomSize(capacity uint64, p float64) (uint64, int) {
+	bits := math.Ceil(-float64(capacity) * math.Log(p) / (math.Ln2 * math.Ln2))
+	if bits > maxBloomBits {
+		bits = maxBloomBits
+	}
+	hashes := int(math.Round(bits / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return uint64(bits), hashes
+}
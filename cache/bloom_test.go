@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   uint64
+		p          float64
+		wantBits   uint64
+		wantHashes int
+	}{
+		{"One percent", 1000, 0.01, 9586, 7},
+		{"One per mille", 1000, 0.001, 14378, 10},
+		{"Capped", 1 << 40, 0.01, 1 << 32, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bits, hashes := bloomSize(tt.capacity, tt.p)
+			assert.Equal(t, tt.wantBits, bits)
+			assert.Equal(t, tt.wantHashes, hashes)
+		})
+	}
+}
+
+func TestBloomFilter_offsets(t *testing.T) {
+	filter := (&Redis{}).NewBloomFilter("users", BloomOptions{Capacity: 1000})
+
+	offsets := filter.offsets("alice@example.com")
+	assert.Len(t, offsets, filter.hashes)
+	assert.Equal(t, offsets, filter.offsets("alice@example.com"))
+	assert.NotEqual(t, offsets, filter.offsets("bob@example.com"))
+	for _, offset := range offsets {
+		assert.Less(t, offset, filter.bits)
+	}
+}
+
+func bloomItems(prefix string, n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return items
+}
+
+func TestBloomFilter_Exists(t *testing.T) {
+	_, r := newTestRedis(t)
+	ctx := context.Background()
+	filter := r.NewBloomFilter("users", BloomOptions{Capacity: 2000})
+
+	exists, err := filter.Exists(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// More than a batch, so both Add and ExistsMany split their pipelines.
+	added := bloomItems("added", 1500)
+	require.NoError(t, filter.Add(ctx, added...))
+	found, err := filter.ExistsMany(ctx, added...)
+	require.NoError(t, err)
+	require.Len(t, found, len(added))
+	for i, exists := range found {
+		assert.True(t, exists, added[i])
+	}
+
+	found, err = filter.ExistsMany(ctx, bloomItems("other", 1000)...)
+	require.NoError(t, err)
+	falsePositives := 0
+	for _, exists := range found {
+		if exists {
+			falsePositives++
+		}
+	}
+	// 1% expected, with a wide margin.
+	assert.Less(t, falsePositives, 50)
+}
+
+func TestBloomFilter_Rebuild(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	filter := r.NewBloomFilter("users", BloomOptions{Capacity: 1000})
+	require.NoError(t, filter.Add(ctx, "old"))
+
+	err := filter.Rebuild(ctx, func(ctx context.Context, add func(items ...string) error) error {
+		require.NoError(t, add("new"))
+		// The previous filter answers until the new one is complete.
+		exists, err := filter.Exists(ctx, "old")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = filter.Exists(ctx, "new")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		return nil
+	})
+	require.NoError(t, err)
+
+	found, err := filter.ExistsMany(ctx, "old", "new")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, true}, found)
+	assert.Equal(t, []string{"bloom:users"}, server.Keys())
+
+	// A failed rebuild keeps the current filter.
+	failure := errors.New("database unavailable")
+	err = filter.Rebuild(ctx, func(ctx context.Context, add func(items ...string) error) error {
+		require.NoError(t, add("partial"))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	found, err = filter.ExistsMany(ctx, "new", "partial")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, found)
+	assert.Equal(t, []string{"bloom:users"}, server.Keys())
+
+	// Rebuilding from nothing empties the filter.
+	require.NoError(t, filter.Rebuild(ctx, func(ctx context.Context, add func(items ...string) error) error {
+		return nil
+	}))
+	assert.Empty(t, server.Keys())
+}
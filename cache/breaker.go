@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 10 * time.Second
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+type BreakerState int
+
+const (
+	// BreakerClosed lets every command through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every command with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through, its outcome closes or
+	// opens the circuit again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type BreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit, it defaults to 5.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe is let
+	// through, it defaults to ten seconds.
+	OpenTimeout time.Duration
+	// FailOpen makes Get, Lookup, GetValue and GetOrLoad report misses and
+	// Store, StoreWithExpire, StoreItems and SetValues drop their values
+	// without an error while the circuit is open. Other operations return
+	// ErrCircuitOpen.
+	FailOpen bool
+	// OnStateChange is called after every transition.
+	OnStateChange func(from, to BreakerState)
+}
+
+// CircuitBreaker stops sending commands to Redis after repeated failures,
+// so callers fail fast instead of waiting for timeouts. Only connection
+// errors and timeouts count as failures, replies such as a missing key or
+// WRONGTYPE don't.
+type CircuitBreaker struct {
+	options BreakerOptions
+	now     func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+type breakerProbeKey struct{}
+
+func NewCircuitBreaker(options BreakerOptions) *CircuitBreaker {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	return &CircuitBreaker{
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// UseCircuitBreaker guards the client of r with breaker. Like Instrument it
+// is meant to be called once, right after creating r, so the copies of r
+// share the fail-open mode.
+func (r *Redis) UseCircuitBreaker(breaker *CircuitBreaker) {
+	r.breaker = breaker
+	r.client.AddHook(breaker)
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *CircuitBreaker) BeforeProcess(ctx context.Context, cmd redisClient.Cmder) (context.Context, error) {
+	return b.before(ctx)
+}
+
+func (b *CircuitBreaker) AfterProcess(ctx context.Context, cmd redisClient.Cmder) error {
+	b.after(ctx, cmd.Err())
+	return nil
+}
+
+func (b *CircuitBreaker) BeforeProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) (context.Context, error) {
+	return b.before(ctx)
+}
+
+// AfterProcessPipeline counts a pipeline as a single command, which failed
+// when any of its commands did.
+func (b *CircuitBreaker) AfterProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if isBreakerFailure(cmd.Err()) {
+			err = cmd.Err()
+			break
+		}
+	}
+	b.after(ctx, err)
+	return nil
+}
+
+// before rejects the command while the circuit is open and marks the
+// context of the probe once the open timeout elapsed.
+func (b *CircuitBreaker) before(ctx context.Context) (context.Context, error) {
+	b.mu.Lock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.options.OpenTimeout {
+			b.mu.Unlock()
+			return ctx, ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.mu.Unlock()
+		b.transition(BreakerOpen, BreakerHalfOpen)
+		return context.WithValue(ctx, breakerProbeKey{}, true), nil
+	case BreakerHalfOpen:
+		b.mu.Unlock()
+		return ctx, ErrCircuitOpen
+	}
+	b.mu.Unlock()
+	return ctx, nil
+}
+
+func (b *CircuitBreaker) after(ctx context.Context, err error) {
+	failed := isBreakerFailure(err)
+	probe, _ := ctx.Value(breakerProbeKey{}).(bool)
+
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case probe && b.state == BreakerHalfOpen:
+		b.failures = 0
+		if failed {
+			b.open()
+		} else {
+			b.state = BreakerClosed
+		}
+	case b.state == BreakerClosed && failed:
+		b.failures++
+		if b.failures >= b.options.FailureThreshold {
+			b.open()
+		}
+	case b.state == BreakerClosed && err != ErrCircuitOpen:
+		b.failures = 0
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	if from != to {
+		b.transition(from, to)
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) transition(from, to BreakerState) {
+	if b.options.OnStateChange != nil {
+		b.options.OnStateChange(from, to)
+	}
+}
+
+// isBreakerFailure reports whether err means Redis is unreachable or too
+// slow, as opposed to a reply or a canceled call.
+func isBreakerFailure(err error) bool {
+	if err == nil || err == redisClient.Nil || err == ErrCircuitOpen || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var reply redisClient.Error
+	return !errors.As(err, &reply)
+}
+
+// failOpen reports whether err comes from an open circuit that r treats as
+// a miss or a dropped write.
+func (r *Redis) failOpen(err error) bool {
+	return r.breaker != nil && r.breaker.options.FailOpen && err == ErrCircuitOpen
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("dial tcp: connection refused")
+	now := time.Unix(0, 0)
+
+	var changes []BreakerState
+	breaker := NewCircuitBreaker(BreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+	breaker.now = func() time.Time { return now }
+
+	process := func(err error) error {
+		ctx, rejected := breaker.before(ctx)
+		if rejected != nil {
+			breaker.after(ctx, rejected)
+			return rejected
+		}
+		breaker.after(ctx, err)
+		return nil
+	}
+
+	assert.NoError(t, process(failure))
+	assert.NoError(t, process(redisClient.Nil))
+	assert.NoError(t, process(failure))
+	assert.Equal(t, BreakerClosed, breaker.State(), "a reply resets the failures")
+
+	assert.NoError(t, process(failure))
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, ErrCircuitOpen, process(nil))
+
+	now = now.Add(time.Second)
+	assert.NoError(t, process(failure), "the probe is let through")
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, ErrCircuitOpen, process(nil))
+
+	now = now.Add(time.Second)
+	probeCtx, err := breaker.before(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	_, err = breaker.before(ctx)
+	assert.Equal(t, ErrCircuitOpen, err, "a single probe at a time")
+	breaker.after(probeCtx, nil)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.Equal(t, []BreakerState{
+		BreakerOpen,
+		BreakerHalfOpen, BreakerOpen,
+		BreakerHalfOpen, BreakerClosed,
+	}, changes)
+}
+
+func TestRedis_UseCircuitBreaker(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker(BreakerOptions{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Second,
+		FailOpen:         true,
+	})
+	breaker.now = func() time.Time { return now }
+	r.UseCircuitBreaker(breaker)
+	require.NoError(t, r.Store(ctx, map[string]string{"key": "a"}))
+
+	server.Close()
+	_, err := r.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrRetrieve)
+	require.Equal(t, BreakerOpen, breaker.State())
+
+	// The open circuit answers without sending anything to the server.
+	require.NoError(t, server.Restart())
+	commands := server.CommandCount()
+
+	data, err := r.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": ""}, data)
+	data, err = r.Lookup(ctx, "key")
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	var got string
+	assert.ErrorIs(t, r.GetValue(ctx, "key", &got), ErrMiss)
+	assert.NoError(t, r.Store(ctx, map[string]string{"dropped": "b"}))
+	assert.NoError(t, r.SetValue(ctx, "dropped", "b", time.Minute))
+
+	assert.Equal(t, commands, server.CommandCount())
+	assert.False(t, server.Exists("dropped"))
+
+	// Once the open timeout elapsed a probe closes the circuit again.
+	now = now.Add(time.Second)
+	data, err = r.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": "a"}, data)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is the set of operations shared by every cache backend, so callers
+// can swap Redis for the in-memory implementation without code changes.
+type Cache interface {
+	Store(ctx context.Context, values map[string]string) error
+	StoreWithExpire(ctx context.Context, values map[string]string, duration time.Duration) error
+	Get(ctx context.Context, keys ...string) (map[string]string, error)
+}
+
+var (
+	_ Cache = (*Redis)(nil)
+	_ Cache = (*Memory)(nil)
+	_ Cache = (*NearCache)(nil)
+)
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	ErrUnsupportedType = errors.New("unsupported type for codec")
+)
+
+// Codec converts values to and from the bytes stored in the cache.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSONCodec    Codec = jsonCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+	GobCodec     Codec = gobCodec{}
+	// RawCodec stores []byte and string values as they are.
+	RawCodec Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch value := v.(type) {
+	case *[]byte:
+		*value = append((*value)[:0], data...)
+	case *string:
+		*value = string(data)
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codecValue struct {
+	Name  string
+	Count int
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"JSON", JSONCodec},
+		{"Msgpack", MsgpackCodec},
+		{"Gob", GobCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := codecValue{Name: "name", Count: 3}
+			data, err := tt.codec.Marshal(want)
+			assert.NoError(t, err)
+
+			var got codecValue
+			assert.NoError(t, tt.codec.Unmarshal(data, &got))
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	data, err := RawCodec.Marshal("value")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	var s string
+	assert.NoError(t, RawCodec.Unmarshal(data, &s))
+	assert.Equal(t, "value", s)
+
+	var b []byte
+	assert.NoError(t, RawCodec.Unmarshal(data, &b))
+	assert.Equal(t, []byte("value"), b)
+
+	_, err = RawCodec.Marshal(1)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	assert.ErrorIs(t, RawCodec.Unmarshal(data, &codecValue{}), ErrUnsupportedType)
+}
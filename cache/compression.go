@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionMagic starts the header of every compressed value, followed
+// by the algorithm and the length of the uncompressed value as a big endian
+// uint32. The version byte lets the header change without misreading the
+// values written before.
+const (
+	compressionMagic      = "\xC1\x5ACZ\x01"
+	compressionHeaderSize = len(compressionMagic) + 5
+)
+
+var (
+	ErrUnknownCompression = errors.New("unknown compression algorithm")
+	ErrCorruptCompression = errors.New("corrupt compressed value")
+)
+
+type CompressionAlgorithm byte
+
+const (
+	NoCompression CompressionAlgorithm = iota
+	Gzip
+	Snappy
+	Zstd
+)
+
+// Compression compresses values of at least Threshold bytes with
+// Algorithm. Compressed values carry a header, see compressionMagic, so
+// they can coexist with uncompressed ones.
+type Compression struct {
+	Algorithm CompressionAlgorithm
+	Threshold int
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// WithCompression returns a copy of r that compresses the values it writes.
+// Compressed values are always decompressed on read, whatever the
+// compression of r.
+func (r Redis) WithCompression(compression Compression) Redis {
+	r.compression = compression
+	return r
+}
+
+// compress returns value with a compression header when it is large enough
+// to be compressed.
+func (c Compression) compress(value string) (string, error) {
+	if c.Algorithm == NoCompression || len(value) < c.Threshold {
+		return value, nil
+	}
+
+	var (
+		data = []byte(value)
+		out  = make([]byte, compressionHeaderSize)
+	)
+	copy(out, compressionMagic)
+	out[len(compressionMagic)] = byte(c.Algorithm)
+	binary.BigEndian.PutUint32(out[len(compressionMagic)+1:], uint32(len(data)))
+	switch c.Algorithm {
+	case Gzip:
+		buffer := bytes.NewBuffer(out)
+		writer := gzip.NewWriter(buffer)
+		if _, err := writer.Write(data); err != nil {
+			return "", err
+		}
+		if err := writer.Close(); err != nil {
+			return "", err
+		}
+		out = buffer.Bytes()
+	case Snappy:
+		out = append(out, snappy.Encode(nil, data)...)
+	case Zstd:
+		out = zstdEncoder.EncodeAll(data, out)
+	default:
+		return "", ErrUnknownCompression
+	}
+	return string(out), nil
+}
+
+// decompress returns the decompressed content of value. Values without a
+// compression header are returned as they are, it fails on values with a
+// header that don't decompress to the length it records.
+func decompress(value string) (string, error) {
+	if !isCompressed(value) {
+		return value, nil
+	}
+
+	var (
+		header = value[len(compressionMagic):compressionHeaderSize]
+		data   = []byte(value[compressionHeaderSize:])
+		out    []byte
+		err    error
+	)
+	switch CompressionAlgorithm(header[0]) {
+	case Gzip:
+		var reader *gzip.Reader
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		out, err = io.ReadAll(reader)
+	case Snappy:
+		out, err = snappy.Decode(nil, data)
+	case Zstd:
+		out, err = zstdDecoder.DecodeAll(data, nil)
+	default:
+		return "", ErrUnknownCompression
+	}
+	if err != nil {
+		return "", err
+	}
+	if uint32(len(out)) != binary.BigEndian.Uint32([]byte(header[1:])) {
+		return "", ErrCorruptCompression
+	}
+	return string(out), nil
+}
+
+func isCompressed(value string) bool {
+	return len(value) >= compressionHeaderSize && strings.HasPrefix(value, compressionMagic)
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"name":"value"}`, 100)
+	tests := []struct {
+		name       string
+		algorithm  CompressionAlgorithm
+		value      string
+		compressed bool
+	}{
+		{"Gzip", Gzip, large, true},
+		{"Snappy", Snappy, large, true},
+		{"Zstd", Zstd, large, true},
+		{"Below threshold", Zstd, `{"name":"value"}`, false},
+		{"Disabled", NoCompression, large, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compression := Compression{Algorithm: tt.algorithm, Threshold: 64}
+			data, err := compression.compress(tt.value)
+			assert.NoError(t, err)
+			if tt.compressed {
+				assert.Less(t, len(data), len(tt.value))
+				assert.True(t, strings.HasPrefix(data, compressionMagic))
+			} else {
+				assert.Equal(t, tt.value, data)
+			}
+
+			got, err := decompress(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+}
+
+// header returns a compression header for algorithm and length.
+func header(algorithm CompressionAlgorithm, length int) string {
+	return compressionMagic + string([]byte{byte(algorithm), 0, 0, 0, byte(length)})
+}
+
+func TestDecompress_RawValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"Empty", ""},
+		{"Former marker", string([]byte{0xC1, byte(Gzip), 'x'})},
+		{"Magic only", compressionMagic},
+		{"Other version", compressionMagic[:len(compressionMagic)-1] + "\x02" + header(Gzip, 1)[len(compressionMagic):]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompress(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+}
+
+func TestDecompress_Corrupt(t *testing.T) {
+	snappyValue, err := Compression{Algorithm: Snappy}.compress("value")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{"Unknown algorithm", header(0xFF, 1) + "a", ErrUnknownCompression},
+		{"Invalid gzip", header(Gzip, 1) + "x", nil},
+		{"Invalid zstd", header(Zstd, 1) + "x", nil},
+		{"Length mismatch", header(Snappy, 4) + snappyValue[compressionHeaderSize:], ErrCorruptCompression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decompress(tt.value)
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRedis_GetValue_RawMarker(t *testing.T) {
+	ctx := context.Background()
+	_, r := newTestRedis(t)
+	raw := r.WithCodec(RawCodec)
+
+	value := []byte{0xC1, byte(Gzip), 'x'}
+	require.NoError(t, raw.SetValue(ctx, "bin", value, 0))
+	var got []byte
+	assert.NoError(t, raw.GetValue(ctx, "bin", &got))
+	assert.Equal(t, value, got)
+}
+
+func TestRedis_Get_CorruptValue(t *testing.T) {
+	ctx := context.Background()
+	server, r := newTestRedis(t)
+	require.NoError(t, server.Set("key", header(Gzip, 1)+"x"))
+
+	_, err := r.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrRetrieve)
+	_, err = r.Lookup(ctx, "key")
+	assert.ErrorIs(t, err, ErrRetrieve)
+	var got string
+	assert.ErrorIs(t, r.GetValue(ctx, "key", &got), ErrRetrieve)
+}
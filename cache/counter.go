@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+var (
+	incrementScript = redisClient.NewScript(`
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+)
+
+// Increment atomically adds by to the counter stored under key and returns
+// the new value. A counter without a TTL, including a new one, expires
+// after expiration unless it is zero.
+func (r *Redis) Increment(ctx context.Context, key string, by int64, expiration time.Duration) (int64, error) {
+	value, err := incrementScript.Run(ctx, r.client, []string{r.key(key)}, by, expiration.Milliseconds()).Int64()
+	if err != nil {
+		return 0, newError("incrby", ErrStore, err, key)
+	}
+	return value, nil
+}
+
+// Decrement atomically subtracts by from the counter stored under key, see
+// Increment.
+func (r *Redis) Decrement(ctx context.Context, key string, by int64, expiration time.Duration) (int64, error) {
+	return r.Increment(ctx, key, -by, expiration)
+}
+
+// Counter returns the value of the counter stored under key, or ErrMiss
+// when it does not exist.
+func (r *Redis) Counter(ctx context.Context, key string) (int64, error) {
+	value, err := r.client.Get(ctx, r.key(key)).Int64()
+	if err == redisClient.Nil {
+		return 0, newError("get", ErrMiss, nil, key)
+	}
+	if err != nil {
+		return 0, newError("get", ErrRetrieve, err, key)
+	}
+	return value, nil
+}
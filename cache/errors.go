@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrRetrieve  = errors.New("unable to retrieve")
+	ErrStore     = errors.New("unable to store")
+	ErrSetExpiry = errors.New("unable to set expiry")
+	ErrDelete    = errors.New("unable to delete")
+	ErrMiss      = errors.New("cache miss")
+)
+
+// Error describes a failed cache operation. It matches its Kind sentinel,
+// such as ErrStore or ErrMiss, with errors.Is and unwraps to the error
+// returned by the client.
+type Error struct {
+	Op   string
+	Keys []string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("cache ")
+	b.WriteString(e.Op)
+	if len(e.Keys) > 0 {
+		b.WriteString(" ")
+		b.WriteString(strings.Join(e.Keys, ", "))
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Kind.Error())
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(op string, kind error, err error, keys ...string) error {
+	return &Error{
+		Op:   op,
+		Keys: keys,
+		Kind: kind,
+		Err:  err,
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	timeout := errors.New("i/o timeout")
+	tests := []struct {
+		name    string
+		err     error
+		kind    error
+		cause   error
+		message string
+	}{
+		{
+			"Store",
+			newError("store", ErrStore, timeout, "a", "b"),
+			ErrStore,
+			timeout,
+			"cache store a, b: unable to store: i/o timeout",
+		},
+		{
+			"Miss",
+			newError("get", ErrMiss, nil, "a"),
+			ErrMiss,
+			nil,
+			"cache get a: cache miss",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.err, tt.kind)
+			assert.Equal(t, tt.cause, errors.Unwrap(tt.err))
+			assert.EqualError(t, tt.err, tt.message)
+			assert.False(t, errors.Is(tt.err, ErrDelete))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+// slidingGetSource reads KEYS and resets the TTL of the ones that have one
+// to ARGV in the same order, keys stored without a TTL keep none.
+const slidingGetSource = `
+local values = redis.call("MGET", unpack(KEYS))
+for i, key in ipairs(KEYS) do
+	if values[i] and redis.call("PTTL", key) > 0 then
+		redis.call("PEXPIRE", key, ARGV[i])
+	end
+end
+return values
+`
+
+var (
+	slidingGetScript = redisClient.NewScript(slidingGetSource)
+)
+
+// Jitter spreads the expiry of keys written together so they don't all
+// expire at once. A random duration of up to Percent percent of the
+// expiration plus Absolute is added to every TTL.
+type Jitter struct {
+	Percent  float64
+	Absolute time.Duration
+}
+
+// WithJitter returns a copy of r that adds jitter to the TTL of the values
+// it writes through StoreWithExpire, StoreItems, SetValues and GetOrLoad.
+func (r Redis) WithJitter(jitter Jitter) Redis {
+	r.jitter = jitter
+	return r
+}
+
+// WithSlidingExpiration returns a copy of r whose Get resets the TTL of the
+// keys it finds to expiration, so keys read often stay cached. Keys stored
+// without a TTL keep none. Zero disables it.
+func (r Redis) WithSlidingExpiration(expiration time.Duration) Redis {
+	r.sliding = expiration
+	return r
+}
+
+// expiration returns duration with the jitter of r, values without a TTL
+// are left as they are.
+func (r *Redis) expiration(duration time.Duration) time.Duration {
+	if duration <= 0 {
+		return duration
+	}
+	spread := time.Duration(float64(duration)*r.jitter.Percent/100) + r.jitter.Absolute
+	if spread <= 0 {
+		return duration
+	}
+	return duration + time.Duration(rand.Int63n(int64(spread)))
+}
+
+// mget reads keys and, with a sliding expiration, resets the TTL of the
+// ones that expire in the same round trip.
+func (r *Redis) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	if r.sliding <= 0 {
+		return r.client.MGet(ctx, r.keys(keys)...).Result()
+	}
+
+	expirations := make([]interface{}, len(keys))
+	for i := range keys {
+		expirations[i] = r.expiration(r.sliding).Milliseconds()
+	}
+	result, err := slidingGetScript.Run(ctx, r.client, r.keys(keys), expirations...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != len(keys) {
+		return nil, fmt.Errorf("unexpected reply %v", result)
+	}
+	return values, nil
+}
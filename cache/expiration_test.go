@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_expiration(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   Jitter
+		duration time.Duration
+		max      time.Duration
+	}{
+		{"Without jitter", Jitter{}, time.Minute, time.Minute},
+		{"Percent", Jitter{Percent: 10}, time.Minute, time.Minute + 6*time.Second},
+		{"Absolute", Jitter{Absolute: time.Second}, time.Minute, time.Minute + time.Second},
+		{"Without expiration", Jitter{Percent: 10, Absolute: time.Second}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Redis{jitter: tt.jitter}
+			for i := 0; i < 100; i++ {
+				expiration := r.expiration(tt.duration)
+				assert.GreaterOrEqual(t, int64(expiration), int64(tt.duration))
+				if tt.max == tt.duration {
+					assert.Equal(t, tt.duration, expiration)
+				} else {
+					assert.Less(t, int64(expiration), int64(tt.max))
+				}
+			}
+		})
+	}
+}
+
+func TestRedis_Get_SlidingExpiration(t *testing.T) {
+	server, r := newTestRedis(t)
+	r = r.WithSlidingExpiration(time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, r.StoreWithExpire(ctx, map[string]string{"expiring": "a"}, time.Minute))
+	require.NoError(t, r.Store(ctx, map[string]string{"persistent": "b"}))
+
+	data, err := r.Get(ctx, "expiring", "persistent", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"expiring": "a", "persistent": "b", "missing": ""}, data)
+
+	assert.Equal(t, time.Hour, server.TTL("expiring"))
+	assert.Equal(t, time.Duration(0), server.TTL("persistent"))
+	assert.False(t, server.Exists("missing"))
+}
+
+func TestRedis_Get_SlidingExpiration_Keys(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	items := map[string]string{"a": "1", "b": "2", "c": "3"}
+	require.NoError(t, r.StoreWithExpire(ctx, items, time.Minute))
+
+	// A plain read leaves the TTLs alone.
+	server.FastForward(30 * time.Second)
+	_, err := r.Get(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	for key := range items {
+		assert.Equal(t, 30*time.Second, server.TTL(key), key)
+	}
+
+	// A sliding read resets the TTL of every key it reads, each with its
+	// own jitter, and only of those.
+	sliding := r.WithSlidingExpiration(time.Hour).WithJitter(Jitter{Absolute: time.Minute})
+	data, err := sliding.Get(ctx, "a", "b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, data)
+	for _, key := range []string{"a", "b"} {
+		assert.GreaterOrEqual(t, int64(server.TTL(key)), int64(time.Hour), key)
+		assert.Less(t, int64(server.TTL(key)), int64(time.Hour+time.Minute), key)
+	}
+	assert.Equal(t, 30*time.Second, server.TTL("c"))
+}
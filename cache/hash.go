@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+const hashTag = "redis"
+
+// HashSet writes the fields of value into the hash stored under key, other
+// fields of the hash are kept. value is either a map with string keys or a
+// struct, or a pointer to one, whose fields are mapped by their
+// `redis:"field"` tag. Untagged and unexported fields are skipped, as are
+// zero fields tagged `redis:"field,omitempty"`. Tagged pointer fields are
+// rejected with ErrUnsupportedType as HashGetAll can't read them back.
+func (r *Redis) HashSet(ctx context.Context, key string, value interface{}) error {
+	fields, err := hashFields(value)
+	if err != nil {
+		return newError("hset", ErrStore, err, key)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := r.client.HSet(ctx, r.key(key), fields).Err(); err != nil {
+		return newError("hset", ErrStore, err, key)
+	}
+	return nil
+}
+
+// HashGetAll reads the hash stored under key into dst, a pointer to a
+// struct with `redis:"field"` tags or to a map[string]string. It returns
+// ErrMiss when the hash does not exist.
+func (r *Redis) HashGetAll(ctx context.Context, key string, dst interface{}) error {
+	cmd := r.client.HGetAll(ctx, r.key(key))
+	values, err := cmd.Result()
+	if err != nil {
+		return newError("hgetall", ErrRetrieve, err, key)
+	}
+	if len(values) == 0 {
+		return newError("hgetall", ErrMiss, nil, key)
+	}
+
+	if m, ok := dst.(*map[string]string); ok {
+		*m = values
+		return nil
+	}
+	if err := cmd.Scan(dst); err != nil {
+		return newError("hgetall", ErrRetrieve, err, key)
+	}
+	return nil
+}
+
+// HashIncrBy atomically adds incr to field of the hash stored under key and
+// returns the new value.
+func (r *Redis) HashIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	value, err := r.client.HIncrBy(ctx, r.key(key), field, incr).Result()
+	if err != nil {
+		return 0, newError("hincrby", ErrStore, err, key)
+	}
+	return value, nil
+}
+
+// HashDelete removes fields from the hash stored under key.
+func (r *Redis) HashDelete(ctx context.Context, key string, fields ...string) error {
+	if err := r.client.HDel(ctx, r.key(key), fields...).Err(); err != nil {
+		return newError("hdel", ErrDelete, err, key)
+	}
+	return nil
+}
+
+func hashFields(value interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	fields := map[string]interface{}{}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, ErrUnsupportedType
+		}
+		iterator := v.MapRange()
+		for iterator.Next() {
+			fields[iterator.Key().String()] = iterator.Value().Interface()
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(t.Field(i).Tag.Get(hashTag), ",")
+			name := tag[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			field := v.Field(i)
+			if field.Kind() == reflect.Ptr {
+				return nil, ErrUnsupportedType
+			}
+			if hasOption(tag[1:], "omitempty") && field.IsZero() {
+				continue
+			}
+			fields[name] = field.Interface()
+		}
+	default:
+		return nil, ErrUnsupportedType
+	}
+	return fields, nil
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
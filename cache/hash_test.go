@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashFields(t *testing.T) {
+	score := 10
+	type profile struct {
+		Name     string  `redis:"name"`
+		Age      int     `redis:"age,omitempty"`
+		Score    float64 `redis:"score"`
+		Ignored  string  `redis:"-"`
+		Other    string
+		hidden   string `redis:"hidden"`
+		Untagged *int
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  map[string]interface{}
+		err   error
+	}{
+		{
+			"Struct",
+			&profile{Name: "name", Age: 3, Score: 1.5, Ignored: "x", Other: "y", Untagged: &score},
+			map[string]interface{}{"name": "name", "age": 3, "score": 1.5},
+			nil,
+		},
+		{
+			"Omit empty",
+			profile{Name: "", hidden: "x"},
+			map[string]interface{}{"name": "", "score": float64(0)},
+			nil,
+		},
+		{
+			"Pointer",
+			struct {
+				Score *int `redis:"score"`
+			}{Score: &score},
+			nil,
+			ErrUnsupportedType,
+		},
+		{
+			"Map",
+			map[string]int{"visits": 1},
+			map[string]interface{}{"visits": 1},
+			nil,
+		},
+		{
+			"Unsupported",
+			[]string{"a"},
+			nil,
+			ErrUnsupportedType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hashFields(tt.value)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRedis_Hash(t *testing.T) {
+	_, r := newTestRedis(t)
+	ctx := context.Background()
+	type profile struct {
+		Name   string  `redis:"name"`
+		Age    int     `redis:"age,omitempty"`
+		Score  float64 `redis:"score"`
+		Active bool    `redis:"active"`
+	}
+
+	want := profile{Name: "name", Age: 3, Score: 1.5, Active: true}
+	require.NoError(t, r.HashSet(ctx, "profile", &want))
+	var got profile
+	require.NoError(t, r.HashGetAll(ctx, "profile", &got))
+	assert.Equal(t, want, got)
+
+	age, err := r.HashIncrBy(ctx, "profile", "age", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), age)
+
+	require.NoError(t, r.HashDelete(ctx, "profile", "score"))
+	var fields map[string]string
+	require.NoError(t, r.HashGetAll(ctx, "profile", &fields))
+	assert.Equal(t, map[string]string{"name": "name", "age": "5", "active": "1"}, fields)
+
+	assert.ErrorIs(t, r.HashSet(ctx, "pointer", struct {
+		Name *string `redis:"name"`
+	}{Name: &want.Name}), ErrUnsupportedType)
+	assert.ErrorIs(t, r.HashGetAll(ctx, "missing", &got), ErrMiss)
+}
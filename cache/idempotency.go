@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	idempotencyPrefix           = "idempotency:"
+	idempotencyInProgress       = "in_progress"
+	idempotencyDone             = "done"
+	defaultIdempotencyClaimTTL  = time.Minute
+	defaultIdempotencyResultTTL = 24 * time.Hour
+)
+
+var (
+	ErrRequestInProgress   = errors.New("request with this idempotency key is in progress")
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+	ErrClaimExpired        = errors.New("idempotency key claim expired")
+)
+
+var (
+	claimScript = redisClient.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("HMGET", KEYS[1], "state", "fingerprint", "response")
+end
+redis.call("HSET", KEYS[1], "state", ARGV[2], "fingerprint", ARGV[1], "token", ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return false
+`)
+	saveScript = redisClient.NewScript(`
+if redis.call("HGET", KEYS[1], "token") ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "state", ARGV[2], "response", ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return 1
+`)
+	extendClaimScript = redisClient.NewScript(`
+local fields = redis.call("HMGET", KEYS[1], "state", "token")
+if fields[2] ~= ARGV[1] then
+	return 0
+end
+if fields[1] == ARGV[2] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return 1
+`)
+	releaseClaimScript = redisClient.NewScript(`
+local fields = redis.call("HMGET", KEYS[1], "state", "token")
+if fields[1] == ARGV[2] and fields[2] == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+type IdempotencyOptions struct {
+	// ClaimTTL is how long a claimed key stays in progress when the request
+	// neither saves nor releases it, it defaults to a minute.
+	ClaimTTL time.Duration
+	// ResponseTTL is how long a saved response is replayed, it defaults to
+	// a day.
+	ResponseTTL time.Duration
+}
+
+// IdempotentResponse is the response replayed to duplicate requests.
+type IdempotentResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore makes sure a request carrying an idempotency key runs
+// once. A request first claims its key, then saves its response, which is
+// returned to every retry of the same request.
+type IdempotencyStore struct {
+	redis   *Redis
+	options IdempotencyOptions
+}
+
+// IdempotencyClaim is the claim of a request on an idempotency key. Only
+// the claim holding the random token it was created with can save, extend
+// or release the key, not a later claim of the same request after this one
+// expired.
+type IdempotencyClaim struct {
+	// Response is the saved response of a request that already ran, nil
+	// when the claim succeeded and the request should run. Save, Extend and
+	// Release are only meant for the latter.
+	Response *IdempotentResponse
+
+	store *IdempotencyStore
+	key   string
+	token string
+}
+
+func (r *Redis) NewIdempotencyStore(options IdempotencyOptions) *IdempotencyStore {
+	if options.ClaimTTL <= 0 {
+		options.ClaimTTL = defaultIdempotencyClaimTTL
+	}
+	if options.ResponseTTL <= 0 {
+		options.ResponseTTL = defaultIdempotencyResultTTL
+	}
+	return &IdempotencyStore{
+		redis:   r,
+		options: options,
+	}
+}
+
+// Claim atomically marks key as in progress for the request identified by
+// fingerprint. The claim carries the saved response when the request
+// already ran. It returns ErrRequestInProgress while the request is running
+// and ErrFingerprintMismatch when key was used by a different request.
+func (s *IdempotencyStore) Claim(ctx context.Context, key, fingerprint string) (*IdempotencyClaim, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, newError("claim", ErrStore, err, key)
+	}
+	claim := &IdempotencyClaim{
+		store: s,
+		key:   key,
+		token: token,
+	}
+
+	result, err := claimScript.Run(ctx, s.redis.client, []string{s.key(key)},
+		fingerprint, idempotencyInProgress, token, s.options.ClaimTTL.Milliseconds()).Result()
+	if err == redisClient.Nil {
+		return claim, nil
+	}
+	if err != nil {
+		return nil, newError("claim", ErrStore, err, key)
+	}
+
+	fields, _ := result.([]interface{})
+	if len(fields) != 3 {
+		return claim, nil
+	}
+	state, _ := fields[0].(string)
+	current, _ := fields[1].(string)
+	if current != fingerprint {
+		return nil, ErrFingerprintMismatch
+	}
+	if state != idempotencyDone {
+		return nil, ErrRequestInProgress
+	}
+
+	data, _ := fields[2].(string)
+	var response IdempotentResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, newError("claim", ErrRetrieve, err, key)
+	}
+	return &IdempotencyClaim{Response: &response, store: s, key: key}, nil
+}
+
+func (c *IdempotencyClaim) Key() string {
+	return c.key
+}
+
+// Save stores the response of the request. It returns ErrClaimExpired when
+// the claim expired before, the request may then have run again.
+func (c *IdempotencyClaim) Save(ctx context.Context, response IdempotentResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return newError("save", ErrStore, err, c.key)
+	}
+
+	saved, err := saveScript.Run(ctx, c.store.redis.client, []string{c.store.key(c.key)},
+		c.token, idempotencyDone, data, c.store.options.ResponseTTL.Milliseconds()).Int()
+	if err != nil {
+		return newError("save", ErrStore, err, c.key)
+	}
+	if saved == 0 {
+		return ErrClaimExpired
+	}
+	return nil
+}
+
+// Extend resets the claim to ClaimTTL, so a request running longer than it
+// isn't run again meanwhile. A saved response keeps its TTL. It returns
+// ErrClaimExpired when the claim expired before.
+func (c *IdempotencyClaim) Extend(ctx context.Context) error {
+	extended, err := extendClaimScript.Run(ctx, c.store.redis.client, []string{c.store.key(c.key)},
+		c.token, idempotencyInProgress, c.store.options.ClaimTTL.Milliseconds()).Int()
+	if err != nil {
+		return newError("extend", ErrSetExpiry, err, c.key)
+	}
+	if extended == 0 {
+		return ErrClaimExpired
+	}
+	return nil
+}
+
+// Release drops the claim without saving a response, so the request can be
+// retried, for example after a transient failure. It leaves the key alone
+// once the claim expired.
+func (c *IdempotencyClaim) Release(ctx context.Context) error {
+	err := releaseClaimScript.Run(ctx, c.store.redis.client, []string{c.store.key(c.key)},
+		c.token, idempotencyInProgress).Err()
+	if err != nil {
+		return newError("release", ErrDelete, err, c.key)
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) key(key string) string {
+	return s.redis.key(idempotencyPrefix + key)
+}
+
+// RequestFingerprint identifies a request by its method, path and body so
+// a reused idempotency key with a different request can be detected.
+func RequestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	server, r := newTestRedis(t)
+	store := r.NewIdempotencyStore(IdempotencyOptions{})
+	ctx := context.Background()
+	fingerprint := RequestFingerprint(http.MethodPost, "/orders", []byte(`{"id":1}`))
+	response := IdempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"id":1}`),
+	}
+
+	claim, err := store.Claim(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Nil(t, claim.Response)
+	assert.Equal(t, time.Minute, server.TTL(idempotencyPrefix+"key"))
+
+	_, err = store.Claim(ctx, "key", fingerprint)
+	assert.ErrorIs(t, err, ErrRequestInProgress)
+	_, err = store.Claim(ctx, "key", "other")
+	assert.ErrorIs(t, err, ErrFingerprintMismatch)
+
+	require.NoError(t, claim.Save(ctx, response))
+	assert.Equal(t, 24*time.Hour, server.TTL(idempotencyPrefix+"key"))
+
+	replay, err := store.Claim(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, &response, replay.Response)
+	assert.ErrorIs(t, replay.Save(ctx, IdempotentResponse{}), ErrClaimExpired, "a replay holds no claim")
+}
+
+func TestIdempotencyStore_Release(t *testing.T) {
+	server, r := newTestRedis(t)
+	store := r.NewIdempotencyStore(IdempotencyOptions{})
+	ctx := context.Background()
+
+	claim, err := store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+	require.NoError(t, claim.Release(ctx))
+	assert.False(t, server.Exists(idempotencyPrefix+"key"))
+
+	claim, err = store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+	assert.Nil(t, claim.Response)
+}
+
+func TestIdempotencyStore_Expired(t *testing.T) {
+	server, r := newTestRedis(t)
+	store := r.NewIdempotencyStore(IdempotencyOptions{ClaimTTL: time.Second})
+	ctx := context.Background()
+
+	claim, err := store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+
+	server.FastForward(500 * time.Millisecond)
+	require.NoError(t, claim.Extend(ctx))
+	assert.Equal(t, time.Second, server.TTL(idempotencyPrefix+"key"))
+
+	server.FastForward(time.Second)
+	assert.ErrorIs(t, claim.Extend(ctx), ErrClaimExpired)
+	assert.ErrorIs(t, claim.Save(ctx, IdempotentResponse{}), ErrClaimExpired)
+}
+
+func TestIdempotencyStore_Reclaimed(t *testing.T) {
+	server, r := newTestRedis(t)
+	store := r.NewIdempotencyStore(IdempotencyOptions{ClaimTTL: time.Second})
+	ctx := context.Background()
+
+	// A retry of the same request claims the key once the first claim
+	// expired, the first one no longer owns it.
+	first, err := store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+	server.FastForward(time.Second)
+	second, err := store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, first.Extend(ctx), ErrClaimExpired)
+	assert.ErrorIs(t, first.Save(ctx, IdempotentResponse{StatusCode: http.StatusOK}), ErrClaimExpired)
+	require.NoError(t, first.Release(ctx))
+	assert.True(t, server.Exists(idempotencyPrefix+"key"))
+
+	require.NoError(t, second.Save(ctx, IdempotentResponse{StatusCode: http.StatusCreated}))
+	replay, err := store.Claim(ctx, "key", "request")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, replay.Response.StatusCode)
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := RequestFingerprint(http.MethodPost, "/orders", []byte("body"))
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, RequestFingerprint(http.MethodPost, "/orders", []byte("body")))
+
+	for _, other := range []string{
+		RequestFingerprint(http.MethodPut, "/orders", []byte("body")),
+		RequestFingerprint(http.MethodPost, "/orders/1", []byte("body")),
+		RequestFingerprint(http.MethodPost, "/orders", []byte("other")),
+		// The separators keep the parts from running into each other.
+		RequestFingerprint(http.MethodPost, "/ordersbody", nil),
+	} {
+		assert.NotEqual(t, fingerprint, other)
+	}
+}
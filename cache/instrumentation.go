@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+// Event describes a command sent to Redis by an instrumented client.
+type Event struct {
+	// Operation is the lower case command name, such as "mget" or "set".
+	Operation string
+	// Prefix is the first segment of the first key of the command, without
+	// the namespace, for example "user" for "user:42:profile".
+	Prefix string
+	// Hits and Misses count the keys found and missing for the commands
+	// that read values, GET, MGET, HGETALL, GETEX and the script of a
+	// sliding expiration read.
+	Hits   int
+	Misses int
+	// Err is the error of the command, a missing key is not an error.
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Observer receives an Event after every command of an instrumented client.
+// It is called synchronously and must be safe for concurrent use.
+type Observer interface {
+	Observe(ctx context.Context, event Event)
+}
+
+type ObserverFunc func(ctx context.Context, event Event)
+
+func (f ObserverFunc) Observe(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+type instrumentationStartKey struct{}
+
+// instrumentationHook reports the commands of a client to observers through
+// the go-redis hooks.
+type instrumentationHook struct {
+	prefix    string
+	observers []Observer
+}
+
+// Instrument reports every command sent by the client of r to observers.
+// The client is shared with the copies of r made by WithNamespace, WithCodec
+// and the like, so it is meant to be called once, right after creating r.
+func (r *Redis) Instrument(observers ...Observer) {
+	r.client.AddHook(instrumentationHook{
+		prefix:    r.prefix,
+		observers: observers,
+	})
+}
+
+func (h instrumentationHook) BeforeProcess(ctx context.Context, cmd redisClient.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, instrumentationStartKey{}, time.Now()), nil
+}
+
+func (h instrumentationHook) AfterProcess(ctx context.Context, cmd redisClient.Cmder) error {
+	h.observe(ctx, cmd)
+	return nil
+}
+
+func (h instrumentationHook) BeforeProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, instrumentationStartKey{}, time.Now()), nil
+}
+
+// AfterProcessPipeline reports each command of the pipeline with the
+// duration of the whole pipeline, MULTI and EXEC are left out.
+func (h instrumentationHook) AfterProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) error {
+	for _, cmd := range cmds {
+		switch cmd.Name() {
+		case "multi", "exec":
+			continue
+		}
+		h.observe(ctx, cmd)
+	}
+	return nil
+}
+
+func (h instrumentationHook) observe(ctx context.Context, cmd redisClient.Cmder) {
+	// go-redis runs a script missing from the server cache again with EVAL,
+	// the retry is reported instead.
+	if err := cmd.Err(); err != nil && cmd.Name() == "evalsha" && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return
+	}
+
+	start, _ := ctx.Value(instrumentationStartKey{}).(time.Time)
+	event := Event{
+		Operation: cmd.Name(),
+		Prefix:    h.keyPrefix(cmd),
+		Start:     start,
+		Duration:  time.Since(start),
+	}
+
+	err := cmd.Err()
+	if err != nil && err != redisClient.Nil {
+		event.Err = err
+	} else {
+		event.Hits, event.Misses = hitsOf(cmd)
+	}
+
+	for _, observer := range h.observers {
+		observer.Observe(ctx, event)
+	}
+}
+
+// keyPrefix returns the first segment of the first key of cmd, an empty
+// string when the command has no key or the key has a single segment.
+func (h instrumentationHook) keyPrefix(cmd redisClient.Cmder) string {
+	args := cmd.Args()
+	position := 1
+	switch cmd.Name() {
+	case "eval", "evalsha":
+		position = 3
+	}
+	if len(args) <= position {
+		return ""
+	}
+	key, ok := args[position].(string)
+	if !ok {
+		return ""
+	}
+
+	key = strings.TrimPrefix(key, h.prefix)
+	if i := strings.Index(key, ":"); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// hitsOf counts the keys found and missing for the commands reading values.
+func hitsOf(cmd redisClient.Cmder) (int, int) {
+	switch cmd := cmd.(type) {
+	case *redisClient.StringCmd:
+		switch cmd.Name() {
+		case "get", "getex":
+			if cmd.Err() == redisClient.Nil {
+				return 0, 1
+			}
+			return 1, 0
+		}
+	case *redisClient.SliceCmd:
+		if cmd.Name() != "mget" {
+			return 0, 0
+		}
+		return countValues(cmd.Val())
+	case *redisClient.Cmd:
+		if !isSlidingGet(cmd) {
+			return 0, 0
+		}
+		values, _ := cmd.Val().([]interface{})
+		return countValues(values)
+	case *redisClient.StringStringMapCmd:
+		if cmd.Name() != "hgetall" {
+			return 0, 0
+		}
+		if len(cmd.Val()) == 0 {
+			return 0, 1
+		}
+		return 1, 0
+	}
+	return 0, 0
+}
+
+func countValues(values []interface{}) (int, int) {
+	var hits, misses int
+	for _, value := range values {
+		if value == nil {
+			misses++
+		} else {
+			hits++
+		}
+	}
+	return hits, misses
+}
+
+// isSlidingGet reports whether cmd runs slidingGetScript.
+func isSlidingGet(cmd redisClient.Cmder) bool {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return false
+	}
+	switch cmd.Name() {
+	case "evalsha":
+		return args[1] == slidingGetScript.Hash()
+	case "eval":
+		return args[1] == slidingGetSource
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentationHook_keyPrefix(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		cmd  redisClient.Cmder
+		want string
+	}{
+		{
+			"Namespaced key",
+			redisClient.NewStringCmd(ctx, "get", "app:user:42:profile"),
+			"user",
+		},
+		{
+			"Script key",
+			redisClient.NewCmd(ctx, "evalsha", "sha", 1, "app:ratelimit:login"),
+			"ratelimit",
+		},
+		{
+			"Single segment",
+			redisClient.NewStringCmd(ctx, "get", "app:counter"),
+			"",
+		},
+		{
+			"Without key",
+			redisClient.NewStatusCmd(ctx, "ping"),
+			"",
+		},
+	}
+	hook := instrumentationHook{prefix: "app:"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hook.keyPrefix(tt.cmd))
+		})
+	}
+}
+
+func TestHitsOf(t *testing.T) {
+	ctx := context.Background()
+	hit := redisClient.NewStringCmd(ctx, "get", "key")
+	miss := redisClient.NewStringCmd(ctx, "get", "key")
+	miss.SetErr(redisClient.Nil)
+
+	tests := []struct {
+		name       string
+		cmd        redisClient.Cmder
+		wantHits   int
+		wantMisses int
+	}{
+		{"Get hit", hit, 1, 0},
+		{"Get miss", miss, 0, 1},
+		{"Set", redisClient.NewStatusCmd(ctx, "set", "key", "value"), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, misses := hitsOf(tt.cmd)
+			assert.Equal(t, tt.wantHits, hits)
+			assert.Equal(t, tt.wantMisses, misses)
+		})
+	}
+}
+
+func TestRedis_Instrument_SlidingGet(t *testing.T) {
+	_, r := newTestRedis(t)
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	r.Instrument(ObserverFunc(func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+	r = r.WithSlidingExpiration(time.Hour)
+	ctx := context.Background()
+	require.NoError(t, r.StoreWithExpire(ctx, map[string]string{"a": "1", "b": "2"}, time.Minute))
+
+	// The first read loads the script with EVAL after a NOSCRIPT, the second
+	// one runs it with EVALSHA.
+	for i := 0; i < 2; i++ {
+		_, err := r.Get(ctx, "a", "b", "missing")
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var operations []string
+	var hits, misses int
+	for _, event := range events {
+		assert.NoError(t, event.Err, event.Operation)
+		operations = append(operations, event.Operation)
+		hits += event.Hits
+		misses += event.Misses
+	}
+	assert.Equal(t, []string{"set", "set", "eval", "evalsha"}, operations)
+	assert.Equal(t, 4, hits)
+	assert.Equal(t, 2, misses)
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+const keySeparator = ":"
+
+// KeyBuilder builds the keys of one family of cached values. Every key
+// ends with the schema version of the family, so bumping Version
+// invalidates all of them without flushing Redis.
+type KeyBuilder struct {
+	Namespace string
+	Version   int
+}
+
+func NewKeyBuilder(namespace string, version int) KeyBuilder {
+	return KeyBuilder{
+		Namespace: namespace,
+		Version:   version,
+	}
+}
+
+// Build joins the namespace, parts and version with ":", for example
+// NewKeyBuilder("user", 3).Build(42, "profile") returns "user:42:profile:v3".
+// A zero version is left out.
+func (b KeyBuilder) Build(parts ...interface{}) string {
+	values := make([]string, 0, len(parts)+2)
+	if b.Namespace != "" {
+		values = append(values, b.Namespace)
+	}
+	for _, part := range parts {
+		values = append(values, fmt.Sprint(part))
+	}
+	if b.Version != 0 {
+		values = append(values, fmt.Sprintf("v%d", b.Version))
+	}
+	return strings.Join(values, keySeparator)
+}
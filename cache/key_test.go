@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder KeyBuilder
+		parts   []interface{}
+		want    string
+	}{
+		{
+			"Versioned",
+			NewKeyBuilder("user", 3),
+			[]interface{}{42, "profile"},
+			"user:42:profile:v3",
+		},
+		{
+			"Without version",
+			NewKeyBuilder("user", 0),
+			[]interface{}{42},
+			"user:42",
+		},
+		{
+			"Without namespace",
+			NewKeyBuilder("", 1),
+			[]interface{}{"session", "abc"},
+			"session:abc:v1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.builder.Build(tt.parts...))
+		})
+	}
+}
+
+func TestRedis_WithNamespace(t *testing.T) {
+	r := Redis{}.WithNamespace("billing").WithNamespace("")
+	assert.Equal(t, "billing:user:1", r.key("user:1"))
+	assert.Equal(t, []string{"billing:a", "billing:b"}, r.keys([]string{"a", "b"}))
+
+	nested := r.WithNamespace("v2")
+	assert.Equal(t, "billing:v2:a", nested.key("a"))
+	assert.Equal(t, "billing:a", r.key("a"))
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const defaultScanCount = 100
+
+// Delete removes keys and returns how many of them existed.
+func (r *Redis) Delete(ctx context.Context, keys ...string) (int64, error) {
+	deleted, err := r.client.Del(ctx, r.keys(keys)...).Result()
+	if err != nil {
+		return 0, newError("delete", ErrDelete, err, keys...)
+	}
+	return deleted, nil
+}
+
+// Unlink works like Delete but reclaims the memory in the background.
+func (r *Redis) Unlink(ctx context.Context, keys ...string) (int64, error) {
+	deleted, err := r.client.Unlink(ctx, r.keys(keys)...).Result()
+	if err != nil {
+		return 0, newError("unlink", ErrDelete, err, keys...)
+	}
+	return deleted, nil
+}
+
+// Exists returns how many of keys exist.
+func (r *Redis) Exists(ctx context.Context, keys ...string) (int64, error) {
+	count, err := r.client.Exists(ctx, r.keys(keys)...).Result()
+	if err != nil {
+		return 0, newError("exists", ErrRetrieve, err, keys...)
+	}
+	return count, nil
+}
+
+// TTL returns the remaining time to live of key, zero when it has no
+// expiry. It returns ErrMiss when the key does not exist.
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, r.key(key)).Result()
+	if err != nil {
+		return 0, newError("ttl", ErrRetrieve, err, key)
+	}
+	switch ttl {
+	case -2:
+		return 0, newError("ttl", ErrMiss, nil, key)
+	case -1:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Expire sets the time to live of key. It returns ErrMiss when the key does
+// not exist.
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	ok, err := r.client.PExpire(ctx, r.key(key), ttl).Result()
+	if err != nil {
+		return newError("expire", ErrSetExpiry, err, key)
+	}
+	if !ok {
+		return newError("expire", ErrMiss, nil, key)
+	}
+	return nil
+}
+
+// Persist removes the expiry of key. It reports false when the key does not
+// exist or has no expiry.
+func (r *Redis) Persist(ctx context.Context, key string) (bool, error) {
+	ok, err := r.client.Persist(ctx, r.key(key)).Result()
+	if err != nil {
+		return false, newError("persist", ErrSetExpiry, err, key)
+	}
+	return ok, nil
+}
+
+// Touch updates the last access time of keys and returns how many of them
+// exist.
+func (r *Redis) Touch(ctx context.Context, keys ...string) (int64, error) {
+	count, err := r.client.Touch(ctx, r.keys(keys)...).Result()
+	if err != nil {
+		return 0, newError("touch", ErrRetrieve, err, keys...)
+	}
+	return count, nil
+}
+
+// ScanIterator walks the keys matching a pattern with SCAN, on a cluster
+// every master is scanned in turn. Keys are returned without the namespace
+// of the Redis they were scanned from.
+type ScanIterator struct {
+	redis   *Redis
+	pattern string
+	count   int64
+	nodes   []scanner
+	current *redisClient.ScanIterator
+	key     string
+	err     error
+}
+
+type scanner interface {
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redisClient.ScanCmd
+}
+
+// Scan returns an iterator over the keys matching the glob-style pattern
+// within the namespace of r. count hints how many keys are fetched per
+// round trip, zero uses a default.
+func (r *Redis) Scan(ctx context.Context, pattern string, count int64) *ScanIterator {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	iterator := &ScanIterator{
+		redis:   r,
+		pattern: r.key(pattern),
+		count:   count,
+	}
+
+	cluster, ok := r.client.(*redisClient.ClusterClient)
+	if !ok {
+		iterator.nodes = []scanner{r.client}
+		return iterator
+	}
+	var mu sync.Mutex
+	iterator.err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redisClient.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		iterator.nodes = append(iterator.nodes, client)
+		return nil
+	})
+	return iterator
+}
+
+// Next advances to the next key, it returns false when the scan is done or
+// failed, see Err.
+func (it *ScanIterator) Next(ctx context.Context) bool {
+	for it.err == nil {
+		if it.current == nil {
+			if len(it.nodes) == 0 {
+				return false
+			}
+			it.current = it.nodes[0].Scan(ctx, 0, it.pattern, it.count).Iterator()
+			it.nodes = it.nodes[1:]
+		}
+		if it.current.Next(ctx) {
+			it.key = strings.TrimPrefix(it.current.Val(), it.redis.prefix)
+			return true
+		}
+		if err := it.current.Err(); err != nil {
+			it.err = newError("scan", ErrRetrieve, err, it.pattern)
+		}
+		it.current = nil
+	}
+	return false
+}
+
+func (it *ScanIterator) Key() string {
+	return it.key
+}
+
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+// DeleteByPattern unlinks every key matching pattern in batches of size
+// keys and returns how many were deleted. It is not atomic, keys written
+// while it runs may survive.
+func (r *Redis) DeleteByPattern(ctx context.Context, pattern string, size int) (int64, error) {
+	if size <= 0 {
+		size = defaultScanCount
+	}
+
+	var (
+		deleted  int64
+		batch    = make([]string, 0, size)
+		iterator = r.Scan(ctx, pattern, int64(size))
+	)
+	for iterator.Next(ctx) {
+		batch = append(batch, r.key(iterator.Key()))
+		if len(batch) < size {
+			continue
+		}
+		count, err := r.unlinkBatch(ctx, batch)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+		batch = batch[:0]
+	}
+	if err := iterator.Err(); err != nil {
+		return deleted, err
+	}
+	count, err := r.unlinkBatch(ctx, batch)
+	return deleted + count, err
+}
+
+// unlinkBatch unlinks keys one command each in a pipeline, so the batch
+// works on a cluster even when keys live in different slots.
+func (r *Redis) unlinkBatch(ctx context.Context, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	cmds, err := r.client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Unlink(ctx, key)
+		}
+		return nil
+	})
+
+	var deleted int64
+	for _, cmd := range cmds {
+		if intCmd, ok := cmd.(*redisClient.IntCmd); ok {
+			deleted += intCmd.Val()
+		}
+	}
+	if err != nil {
+		return deleted, newError("unlink", ErrDelete, err, failedKeys(keys, cmds)...)
+	}
+	return deleted, nil
+}
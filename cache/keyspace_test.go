@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_Keyspace(t *testing.T) {
+	server, base := newTestRedis(t)
+	r := base.WithNamespace("app")
+	ctx := context.Background()
+
+	require.NoError(t, server.Set("app:a", "1"))
+	require.NoError(t, server.Set("app:b", "2"))
+	require.NoError(t, server.Set("a", "outside"))
+
+	count, err := r.Exists(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = r.Touch(ctx, "a", "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	deleted, err := r.Delete(ctx, "a", "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, server.Exists("app:a"))
+	assert.True(t, server.Exists("a"))
+
+	deleted, err = r.Unlink(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, server.Exists("app:b"))
+}
+
+func TestRedis_TTL(t *testing.T) {
+	server, base := newTestRedis(t)
+	r := base.WithNamespace("app")
+	ctx := context.Background()
+	require.NoError(t, server.Set("app:key", "value"))
+
+	// PTTL reports -1 without an expiry and -2 for a missing key.
+	ttl, err := r.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+	_, err = r.TTL(ctx, "missing")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	require.NoError(t, r.Expire(ctx, "key", time.Minute))
+	ttl, err = r.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	assert.ErrorIs(t, r.Expire(ctx, "missing", time.Minute), ErrMiss)
+
+	persisted, err := r.Persist(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, persisted)
+	assert.Equal(t, time.Duration(0), server.TTL("app:key"))
+	persisted, err = r.Persist(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, persisted)
+}
+
+func TestRedis_Scan(t *testing.T) {
+	server, base := newTestRedis(t)
+	r := base.WithNamespace("app")
+	ctx := context.Background()
+	for _, key := range []string{"app:user:1", "app:user:2", "app:order:1", "user:3"} {
+		require.NoError(t, server.Set(key, "x"))
+	}
+
+	var keys []string
+	iterator := r.Scan(ctx, "user:*", 1)
+	for iterator.Next(ctx) {
+		keys = append(keys, iterator.Key())
+	}
+	require.NoError(t, iterator.Err())
+	sort.Strings(keys)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+}
+
+func TestRedis_DeleteByPattern(t *testing.T) {
+	server, base := newTestRedis(t)
+	r := base.WithNamespace("app")
+	ctx := context.Background()
+	for _, key := range []string{"app:user:1", "app:user:2", "app:user:3", "app:order:1", "user:1", "other:user:1"} {
+		require.NoError(t, server.Set(key, "x"))
+	}
+
+	// miniredis shifts its SCAN cursor when keys are deleted, unlike Redis,
+	// so the batch holds every key.
+	deleted, err := r.DeleteByPattern(ctx, "user:*", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	keys := server.Keys()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"app:order:1", "other:user:1", "user:1"}, keys)
+
+	// Every key of the namespace, and only them.
+	deleted, err = r.DeleteByPattern(ctx, "*", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	keys = server.Keys()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"other:user:1", "user:1"}, keys)
+}
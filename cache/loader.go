@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrNotFound = errors.New("value not found")
+)
+
+// notFoundPrefix prefixes the key caching that a loader reported
+// ErrNotFound, so the other reads never see the negative entry.
+const notFoundPrefix = "not-found:"
+
+// Loader fetches the value for a key from the source of truth on a cache
+// miss. It returns ErrNotFound when the value does not exist.
+type Loader func(ctx context.Context) (interface{}, error)
+
+type LoadOptions struct {
+	// Expiration is the TTL of a loaded value, zero stores it without one.
+	Expiration time.Duration
+	// NegativeExpiration is the TTL of a cached ErrNotFound result, zero
+	// disables negative caching.
+	NegativeExpiration time.Duration
+}
+
+// GetOrLoad decodes the cached value of key into dst with the codec of r.
+// On a miss it calls loader, caches the result and decodes it into dst.
+// Concurrent misses for the same key share a single loader call.
+func (r *Redis) GetOrLoad(ctx context.Context, key string, dst interface{}, options LoadOptions, loader Loader) error {
+	value, err := r.client.Get(ctx, r.key(key)).Result()
+	if err == redisClient.Nil && options.NegativeExpiration > 0 {
+		var found int64
+		found, err = r.client.Exists(ctx, r.notFoundKey(key)).Result()
+		if err == nil && found > 0 {
+			return ErrNotFound
+		}
+		if err == nil {
+			err = redisClient.Nil
+		}
+	}
+	switch {
+	case err == redisClient.Nil || r.failOpen(err):
+		var found bool
+		value, found, err = r.load(ctx, key, options, loader)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+	case err == nil:
+		value, err = decompress(value)
+	}
+	if err != nil {
+		return newError("get", ErrRetrieve, err, key)
+	}
+	return r.codec.Unmarshal([]byte(value), dst)
+}
+
+func (r *Redis) notFoundKey(key string) string {
+	return r.key(notFoundPrefix + key)
+}
+
+// loaded is the result of a loader shared by concurrent misses.
+type loaded struct {
+	value string
+	found bool
+}
+
+// load calls loader and caches its result, found is false when it reported
+// ErrNotFound.
+func (r *Redis) load(ctx context.Context, key string, options LoadOptions, loader Loader) (string, bool, error) {
+	result, err, _ := r.group.Do(r.key(key), func() (interface{}, error) {
+		result, err := loader(ctx)
+		if errors.Is(err, ErrNotFound) {
+			if options.NegativeExpiration > 0 {
+				_ = r.client.Set(ctx, r.notFoundKey(key), 1, r.expiration(options.NegativeExpiration)).Err()
+			}
+			return loaded{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := r.codec.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		// The loaded value is still returned when it can't be cached, the
+		// next call will simply load it again.
+		if value, err := r.compression.compress(string(data)); err == nil {
+			_ = r.client.Set(ctx, r.key(key), value, r.expiration(options.Expiration)).Err()
+		}
+		return loaded{value: string(data), found: true}, nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	value := result.(loaded)
+	return value.value, value.found, nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_GetOrLoad(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, r.GetOrLoad(ctx, "key", &results[i], LoadOptions{Expiration: time.Minute}, loader))
+		}(i)
+	}
+	// Let the goroutines miss while the loader runs, a late one reads the
+	// cached value.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, result := range results {
+		assert.Equal(t, 42, result)
+	}
+	assert.Equal(t, time.Minute, server.TTL("key"))
+
+	var cached int
+	require.NoError(t, r.GetOrLoad(ctx, "key", &cached, LoadOptions{}, loader))
+	assert.Equal(t, 42, cached)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRedis_GetOrLoad_NotFound(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	options := LoadOptions{NegativeExpiration: time.Minute}
+	calls := 0
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+
+	var dst string
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "key", &dst, options, loader), ErrNotFound)
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "key", &dst, options, loader), ErrNotFound)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, time.Minute, server.TTL(notFoundPrefix+"key"))
+
+	// The negative entry is invisible to the other reads.
+	data, err := r.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "", data["key"])
+	assert.ErrorIs(t, r.GetValue(ctx, "key", &dst), ErrMiss)
+
+	// It expires like any other entry.
+	server.FastForward(time.Minute)
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "key", &dst, options, loader), ErrNotFound)
+	assert.Equal(t, 2, calls)
+
+	// Without negative caching the loader runs every time.
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "other", &dst, LoadOptions{}, loader), ErrNotFound)
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "other", &dst, LoadOptions{}, loader), ErrNotFound)
+	assert.Equal(t, 4, calls)
+	assert.False(t, server.Exists(notFoundPrefix+"other"))
+}
+
+func TestRedis_GetOrLoad_Error(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	failure := errors.New("database unavailable")
+	calls := 0
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, failure
+		}
+		return "value", nil
+	}
+
+	var dst string
+	options := LoadOptions{NegativeExpiration: time.Minute}
+	assert.ErrorIs(t, r.GetOrLoad(ctx, "key", &dst, options, loader), failure)
+	assert.Empty(t, server.Keys())
+
+	require.NoError(t, r.GetOrLoad(ctx, "key", &dst, options, loader))
+	assert.Equal(t, "value", dst)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrNotObtained    = errors.New("lock not obtained")
+	ErrLockNotHeld    = errors.New("lock not held")
+	ErrInvalidLockTTL = errors.New("lock ttl must be at least a millisecond")
+)
+
+var (
+	releaseScript = redisClient.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+	extendScript = redisClient.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+type LockOptions struct {
+	// RetryInterval is the wait before the second attempt, it doubles after
+	// every failed attempt. Zero makes Obtain try only once.
+	RetryInterval time.Duration
+	// MaxRetryInterval caps the wait between attempts.
+	MaxRetryInterval time.Duration
+	// AutoRenew extends the lock by its TTL in the background until it is
+	// released.
+	AutoRenew bool
+}
+
+// Lock is a mutual exclusion lock held in Redis. Only the holder of the
+// random token it was obtained with can extend or release it.
+type Lock struct {
+	redis *Redis
+	key   string
+	token string
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	lost chan struct{}
+}
+
+// Obtain acquires the lock stored under key for ttl, at least a
+// millisecond. It retries with backoff according to options and returns
+// ErrNotObtained when the lock is held by someone else, or the error of ctx
+// when it is done before the lock is obtained.
+func (r *Redis) Obtain(ctx context.Context, key string, ttl time.Duration, options LockOptions) (*Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, ErrInvalidLockTTL
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	wait := options.RetryInterval
+	for {
+		ok, err := r.client.SetNX(ctx, r.key(key), token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if wait <= 0 {
+			return nil, ErrNotObtained
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("obtain lock %s: %w", key, ctx.Err())
+		case <-timer.C:
+		}
+		wait *= 2
+		if options.MaxRetryInterval > 0 && wait > options.MaxRetryInterval {
+			wait = options.MaxRetryInterval
+		}
+	}
+
+	lock := &Lock{
+		redis: r,
+		key:   r.key(key),
+		token: token,
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+	}
+	if options.AutoRenew {
+		lock.stop = make(chan struct{})
+		go lock.renew(lock.stop)
+	}
+	return lock, nil
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Lost is closed when auto-renewal finds the lock is no longer held.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Extend resets the TTL of the lock, it fails with ErrLockNotHeld when the
+// lock expired or was taken by someone else.
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	result, err := extendScript.Run(ctx, l.redis.client, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Release stops auto-renewal and deletes the lock if it is still held.
+func (l *Lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.mu.Unlock()
+
+	result, err := releaseScript.Run(ctx, l.redis.client, []string{l.key}, l.token).Int()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *Lock) renew(stop <-chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.ttl/2)
+			err := l.Extend(ctx, l.ttl)
+			cancel()
+			if errors.Is(err, ErrLockNotHeld) {
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_Obtain(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	lock, err := r.Obtain(ctx, "lock", time.Minute, LockOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token(), mustGet(t, server, "lock"))
+	assert.Equal(t, time.Minute, server.TTL("lock"))
+
+	_, err = r.Obtain(ctx, "lock", time.Minute, LockOptions{})
+	assert.ErrorIs(t, err, ErrNotObtained)
+
+	require.NoError(t, lock.Release(ctx))
+	assert.False(t, server.Exists("lock"))
+	assert.ErrorIs(t, lock.Release(ctx), ErrLockNotHeld)
+}
+
+func TestRedis_Obtain_InvalidTTL(t *testing.T) {
+	_, r := newTestRedis(t)
+	for _, ttl := range []time.Duration{-time.Second, 0, time.Nanosecond} {
+		_, err := r.Obtain(context.Background(), "lock", ttl, LockOptions{AutoRenew: true})
+		assert.ErrorIs(t, err, ErrInvalidLockTTL)
+	}
+}
+
+func TestRedis_Obtain_Retry(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, server.Set("lock", "other"))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		server.Del("lock")
+	}()
+	lock, err := r.Obtain(ctx, "lock", time.Minute, LockOptions{
+		RetryInterval:    time.Millisecond,
+		MaxRetryInterval: 5 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token(), mustGet(t, server, "lock"))
+}
+
+func TestRedis_Obtain_ContextDone(t *testing.T) {
+	server, r := newTestRedis(t)
+	require.NoError(t, server.Set("lock", "other"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := r.Obtain(ctx, "lock", time.Minute, LockOptions{RetryInterval: time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrNotObtained)
+}
+
+func TestLock_Extend(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	lock, err := r.Obtain(ctx, "lock", time.Minute, LockOptions{})
+	require.NoError(t, err)
+	require.NoError(t, lock.Extend(ctx, time.Hour))
+	assert.Equal(t, time.Hour, server.TTL("lock"))
+
+	require.NoError(t, server.Set("lock", "other"))
+	assert.ErrorIs(t, lock.Extend(ctx, time.Hour), ErrLockNotHeld)
+	assert.ErrorIs(t, lock.Release(ctx), ErrLockNotHeld)
+	assert.Equal(t, "other", mustGet(t, server, "lock"))
+}
+
+func TestLock_AutoRenew(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	lock, err := r.Obtain(ctx, "lock", 20*time.Millisecond, LockOptions{AutoRenew: true})
+	require.NoError(t, err)
+
+	server.SetTTL("lock", time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return server.TTL("lock") == 20*time.Millisecond
+	}, time.Second, time.Millisecond)
+
+	server.Del("lock")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost lock not reported")
+	}
+}
+
+func mustGet(t *testing.T, server *miniredis.Miniredis, key string) string {
+	value, err := server.Get(key)
+	require.NoError(t, err)
+	return value
+}
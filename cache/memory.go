@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a process-local Cache. Entries honour their expiry and, when a
+// capacity is set, the least recently used entry is evicted to make room.
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+type memoryItem struct {
+	key      string
+	value    string
+	expireAt time.Time
+}
+
+// NewMemory creates an in-memory cache holding at most capacity entries.
+// A capacity of zero or less means the cache is unbounded.
+func NewMemory(capacity int) *Memory {
+	return &Memory{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (m *Memory) Store(ctx context.Context, values map[string]string) error {
+	return m.StoreWithExpire(ctx, values, 0)
+}
+
+func (m *Memory) StoreWithExpire(ctx context.Context, values map[string]string, duration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expireAt time.Time
+	if duration > 0 {
+		expireAt = m.now().Add(duration)
+	}
+	for key, value := range values {
+		m.set(key, value, expireAt)
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, keys ...string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := map[string]string{}
+	for _, key := range keys {
+		item, ok := m.get(key)
+		if ok {
+			data[key] = item.value
+		} else {
+			data[key] = ""
+		}
+	}
+	return data, nil
+}
+
+// Lookup works like Get but leaves missing keys out of the result.
+func (m *Memory) Lookup(ctx context.Context, keys ...string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := map[string]string{}
+	for _, key := range keys {
+		if item, ok := m.get(key); ok {
+			data[key] = item.value
+		}
+	}
+	return data, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := m.items[key]; ok {
+			m.remove(element)
+		}
+	}
+	return nil
+}
+
+// Flush removes every entry.
+func (m *Memory) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items = map[string]*list.Element{}
+	m.order.Init()
+}
+
+// Len returns the number of entries currently held, including expired
+// entries that have not been evicted yet.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) set(key, value string, expireAt time.Time) {
+	if element, ok := m.items[key]; ok {
+		item := element.Value.(*memoryItem)
+		item.value = value
+		item.expireAt = expireAt
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.items[key] = m.order.PushFront(&memoryItem{
+		key:      key,
+		value:    value,
+		expireAt: expireAt,
+	})
+	for m.capacity > 0 && m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *Memory) get(key string) (*memoryItem, bool) {
+	element, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*memoryItem)
+	if m.expired(item) {
+		m.remove(element)
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	return item, true
+}
+
+func (m *Memory) expired(item *memoryItem) bool {
+	return !item.expireAt.IsZero() && !m.now().Before(item.expireAt)
+}
+
+func (m *Memory) remove(element *list.Element) {
+	item := m.order.Remove(element).(*memoryItem)
+	delete(m.items, item.key)
+}
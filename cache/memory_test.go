@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Get(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		values  map[string]string
+		expire  time.Duration
+		elapsed time.Duration
+		want    map[string]string
+	}{
+		{
+			"Without expiry",
+			map[string]string{"a": "1"},
+			0,
+			time.Hour,
+			map[string]string{"a": "1", "b": ""},
+		},
+		{
+			"Before expiry",
+			map[string]string{"a": "1"},
+			time.Minute,
+			time.Second,
+			map[string]string{"a": "1", "b": ""},
+		},
+		{
+			"After expiry",
+			map[string]string{"a": "1"},
+			time.Minute,
+			time.Minute,
+			map[string]string{"a": "", "b": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemory(0)
+			m.now = func() time.Time { return now }
+			assert.NoError(t, m.StoreWithExpire(ctx, tt.values, tt.expire))
+
+			m.now = func() time.Time { return now.Add(tt.elapsed) }
+			got, err := m.Get(ctx, "a", "b")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemory_Eviction(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	assert.NoError(t, m.Store(ctx, map[string]string{"a": "1"}))
+	assert.NoError(t, m.Store(ctx, map[string]string{"b": "2"}))
+
+	_, err := m.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Store(ctx, map[string]string{"c": "3"}))
+
+	got, err := m.Get(ctx, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "", "c": "3"}, got)
+	assert.Equal(t, 2, m.Len())
+}
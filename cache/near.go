@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultInvalidationChannel = "cache:invalidate"
+	defaultNearExpiration      = time.Minute
+	nearChannelSize            = 100
+)
+
+type NearCacheOptions struct {
+	// Capacity is the number of entries kept in process, zero means
+	// unbounded.
+	Capacity int
+	// Expiration bounds how long a local copy is served without asking
+	// Redis, it defaults to a minute. It also bounds how long a value read
+	// while another instance changed it stays stale, as the invalidation
+	// may be received before the value is copied.
+	Expiration time.Duration
+	// Channel is the pub/sub channel invalidations are published on, it
+	// defaults to DefaultInvalidationChannel.
+	Channel string
+}
+
+// NearCache keeps recently read keys in process in front of Redis. Writes
+// and deletes are published on a Redis channel so every instance evicts its
+// local copy.
+type NearCache struct {
+	redis   *Redis
+	local   *Memory
+	options NearCacheOptions
+	id      string
+	pubsub  *redisClient.PubSub
+}
+
+type invalidation struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+// NewNearCache subscribes to the invalidation channel and returns a near
+// cache in front of r. Close must be called to stop the subscription.
+func NewNearCache(ctx context.Context, r *Redis, options NearCacheOptions) (*NearCache, error) {
+	if options.Channel == "" {
+		options.Channel = DefaultInvalidationChannel
+	}
+	if options.Expiration <= 0 {
+		options.Expiration = defaultNearExpiration
+	}
+	id, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pubsub := r.client.Subscribe(ctx, r.key(options.Channel))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	near := &NearCache{
+		redis:   r,
+		local:   NewMemory(options.Capacity),
+		options: options,
+		id:      id,
+		pubsub:  pubsub,
+	}
+	go near.listen(pubsub.ChannelWithSubscriptions(ctx, nearChannelSize))
+	return near, nil
+}
+
+func (n *NearCache) Store(ctx context.Context, values map[string]string) error {
+	return n.StoreWithExpire(ctx, values, 0)
+}
+
+func (n *NearCache) StoreWithExpire(ctx context.Context, values map[string]string, duration time.Duration) error {
+	if err := n.redis.StoreWithExpire(ctx, values, duration); err != nil {
+		return err
+	}
+
+	expiration := n.options.Expiration
+	if duration > 0 && duration < expiration {
+		expiration = duration
+	}
+	_ = n.local.StoreWithExpire(ctx, values, expiration)
+	return n.publish(ctx, keysOf(values))
+}
+
+func (n *NearCache) Get(ctx context.Context, keys ...string) (map[string]string, error) {
+	data, _ := n.local.Lookup(ctx, keys...)
+	if len(data) == len(keys) {
+		return data, nil
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return data, nil
+	}
+	found, err := n.redis.Lookup(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	_ = n.local.StoreWithExpire(ctx, found, n.options.Expiration)
+
+	for _, key := range missing {
+		data[key] = found[key]
+	}
+	return data, nil
+}
+
+// Delete removes keys from Redis and from the local copy of every instance.
+func (n *NearCache) Delete(ctx context.Context, keys ...string) error {
+	if _, err := n.redis.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	_ = n.local.Delete(ctx, keys...)
+	return n.publish(ctx, keys)
+}
+
+// Close stops listening for invalidations.
+func (n *NearCache) Close() error {
+	return n.pubsub.Close()
+}
+
+func (n *NearCache) publish(ctx context.Context, keys []string) error {
+	message, err := json.Marshal(invalidation{Source: n.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return n.redis.client.Publish(ctx, n.redis.key(n.options.Channel), message).Err()
+}
+
+// listen evicts the keys invalidated by other instances. go-redis
+// resubscribes after a reconnection, every local copy is dropped then.
+func (n *NearCache) listen(messages <-chan interface{}) {
+	for message := range messages {
+		switch message := message.(type) {
+		case *redisClient.Subscription:
+			n.local.Flush()
+		case *redisClient.Message:
+			var data invalidation
+			if err := json.Unmarshal([]byte(message.Payload), &data); err != nil {
+				continue
+			}
+			if data.Source == n.id {
+				continue
+			}
+			_ = n.local.Delete(context.Background(), data.Keys...)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestNearCache(t *testing.T, r *Redis, options NearCacheOptions) *NearCache {
+	near, err := NewNearCache(context.Background(), r, options)
+	require.NoError(t, err)
+	t.Cleanup(func() { near.Close() })
+	return near
+}
+
+func TestNewNearCache_Defaults(t *testing.T) {
+	_, r := newTestRedis(t)
+	near := newTestNearCache(t, &r, NearCacheOptions{})
+
+	assert.Equal(t, DefaultInvalidationChannel, near.options.Channel)
+	assert.Equal(t, defaultNearExpiration, near.options.Expiration)
+}
+
+func TestNearCache_Get(t *testing.T) {
+	server, r := newTestRedis(t)
+	near := newTestNearCache(t, &r, NearCacheOptions{})
+	ctx := context.Background()
+
+	require.NoError(t, server.Set("remote", "a"))
+	data, err := near.Get(ctx, "remote", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"remote": "a", "missing": ""}, data)
+
+	// The local copy is served without asking Redis.
+	server.Del("remote")
+	data, err = near.Get(ctx, "remote", "remote")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"remote": "a"}, data)
+}
+
+func TestNearCache_Invalidation(t *testing.T) {
+	_, r := newTestRedis(t)
+	first := newTestNearCache(t, &r, NearCacheOptions{})
+	second := newTestNearCache(t, &r, NearCacheOptions{})
+	ctx := context.Background()
+
+	require.NoError(t, first.Store(ctx, map[string]string{"key": "a"}))
+	data, err := second.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "a", data["key"])
+
+	require.NoError(t, first.Store(ctx, map[string]string{"key": "b"}))
+	assert.Eventually(t, func() bool {
+		data, err := second.Get(ctx, "key")
+		return err == nil && data["key"] == "b"
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, first.Delete(ctx, "key"))
+	assert.Eventually(t, func() bool {
+		return second.local.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNearCache_Reconnect(t *testing.T) {
+	server, r := newTestRedis(t)
+	near := newTestNearCache(t, &r, NearCacheOptions{})
+	ctx := context.Background()
+
+	require.NoError(t, near.Store(ctx, map[string]string{"key": "a"}))
+	require.Equal(t, 1, near.local.Len())
+
+	server.Close()
+	require.NoError(t, server.Restart())
+	assert.Eventually(t, func() bool {
+		return near.local.Len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid redis url")
+)
+
+// RedisOptions configures a standalone, sentinel or cluster client. A
+// sentinel client is created when MasterName is set, a cluster client when
+// Cluster is set or Addrs holds several addresses.
+type RedisOptions struct {
+	redisClient.UniversalOptions
+
+	// Cluster creates a cluster client even for a single seed address.
+	Cluster bool
+	// Namespace prefixes every key, see Redis.WithNamespace.
+	Namespace string
+}
+
+// Client creates the go-redis client described by o.
+func (o RedisOptions) Client() redisClient.UniversalClient {
+	if o.Cluster && o.MasterName == "" {
+		return redisClient.NewClusterClient(o.UniversalOptions.Cluster())
+	}
+	return redisClient.NewUniversalClient(&o.UniversalOptions)
+}
+
+// ParseRedisURL parses the following forms into options:
+//
+//	redis://[username:password@]host:port[,host:port...][/db]
+//	rediss://[username:password@]host:port[,host:port...][/db]
+//	redis+sentinel://[username:password@]host:port[,host:port...]/master[/db]
+//	rediss+sentinel://[username:password@]host:port[,host:port...]/master[/db]
+//
+// The rediss schemes enable TLS. Several hosts create a cluster client
+// unless the scheme is a sentinel one, the cluster=true query parameter
+// forces a cluster client and sentinel_password sets the sentinel password.
+func ParseRedisURL(rawURL string) (RedisOptions, error) {
+	var options RedisOptions
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return options, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	var sentinel bool
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	case "redis+sentinel":
+		sentinel = true
+	case "rediss+sentinel":
+		sentinel = true
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	default:
+		return options, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return options, fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	options.Addrs = strings.Split(u.Host, ",")
+	if options.TLSConfig != nil {
+		options.TLSConfig.ServerName = hostOf(options.Addrs[0])
+	}
+	if u.User != nil {
+		options.Username = u.User.Username()
+		options.Password, _ = u.User.Password()
+	}
+
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if sentinel {
+		if path[0] == "" {
+			return options, fmt.Errorf("%w: missing sentinel master name", ErrInvalidURL)
+		}
+		options.MasterName, path = path[0], path[1:]
+	}
+	if len(path) > 1 {
+		return options, fmt.Errorf("%w: invalid path %q", ErrInvalidURL, u.Path)
+	}
+	if len(path) == 1 && path[0] != "" {
+		options.DB, err = strconv.Atoi(path[0])
+		if err != nil {
+			return options, fmt.Errorf("%w: invalid database %q", ErrInvalidURL, path[0])
+		}
+	}
+
+	query := u.Query()
+	options.SentinelPassword = query.Get("sentinel_password")
+	if cluster := query.Get("cluster"); cluster != "" {
+		options.Cluster, err = strconv.ParseBool(cluster)
+		if err != nil {
+			return options, fmt.Errorf("%w: invalid cluster %q", ErrInvalidURL, cluster)
+		}
+	}
+	return options, nil
+}
+
+// DefaultRedisOptions reads the client settings from viper. REDIS_URL is
+// parsed with ParseRedisURL, otherwise the client connects to REDIS_ADDRS,
+// a comma-separated list, or REDIS_URI and REDIS_PORT.
+//
+// REDIS_USERNAME, REDIS_PASSWORD, REDIS_DB, REDIS_MASTER_NAME,
+// REDIS_SENTINEL_PASSWORD and REDIS_CLUSTER complete the settings when no
+// url is given. REDIS_NAMESPACE sets the key namespace. REDIS_TLS enables
+// TLS, REDIS_CA_CERT verifies the server with a custom CA and
+// REDIS_CLIENT_CERT with REDIS_CLIENT_KEY present a client certificate.
+// The options are empty when a setting can't be parsed.
+func DefaultRedisOptions() (RedisOptions, error) {
+	var (
+		options RedisOptions
+		err     error
+	)
+	if rawURL := viper.GetString("REDIS_URL"); rawURL != "" {
+		options, err = ParseRedisURL(rawURL)
+		if err != nil {
+			return RedisOptions{}, err
+		}
+	} else {
+		if addrs := viper.GetString("REDIS_ADDRS"); addrs != "" {
+			options.Addrs = strings.Split(addrs, ",")
+		} else {
+			options.Addrs = []string{fmt.Sprintf("%s:%s", viper.GetString("REDIS_URI"), viper.GetString("REDIS_PORT"))}
+		}
+		options.Username = viper.GetString("REDIS_USERNAME")
+		options.Password = viper.GetString("REDIS_PASSWORD")
+		options.DB = viper.GetInt("REDIS_DB")
+		options.MasterName = viper.GetString("REDIS_MASTER_NAME")
+		options.SentinelPassword = viper.GetString("REDIS_SENTINEL_PASSWORD")
+		options.Cluster = viper.GetBool("REDIS_CLUSTER")
+	}
+
+	options.Namespace = viper.GetString("REDIS_NAMESPACE")
+
+	tlsConfig, err := defaultTLSConfig(options)
+	if err != nil {
+		return RedisOptions{}, err
+	}
+	options.TLSConfig = tlsConfig
+	return options, nil
+}
+
+// defaultTLSConfig returns the TLS configuration of options completed with
+// the REDIS_TLS, REDIS_CA_CERT, REDIS_CLIENT_CERT and REDIS_CLIENT_KEY
+// settings. options is left untouched so a failure doesn't half apply them.
+func defaultTLSConfig(options RedisOptions) (*tls.Config, error) {
+	caCert := viper.GetString("REDIS_CA_CERT")
+	clientCert := viper.GetString("REDIS_CLIENT_CERT")
+	clientKey := viper.GetString("REDIS_CLIENT_KEY")
+
+	config := options.TLSConfig
+	if config == nil {
+		if !viper.GetBool("REDIS_TLS") && caCert == "" && clientCert == "" {
+			return nil, nil
+		}
+		config = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: hostOf(options.Addrs[0]),
+		}
+	} else {
+		config = config.Clone()
+	}
+
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("redis ca cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis ca cert: no certificate found in %s", caCert)
+		}
+		config.RootCAs = pool
+	}
+	if clientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("redis client cert: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
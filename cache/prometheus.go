@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector is an Observer exposing hits, misses, errors and
+// latency per operation and key prefix. Register it with a
+// prometheus.Registerer and pass it to Redis.Instrument.
+type PrometheusCollector struct {
+	hits     *prometheus.CounterVec
+	misses   *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var _ prometheus.Collector = (*PrometheusCollector)(nil)
+
+// NewPrometheusCollector creates the collector, namespace is prepended to
+// the metric names, for example "app" for "app_cache_hits_total".
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	labels := []string{"operation", "prefix"}
+	return &PrometheusCollector{
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "hits_total",
+			Help:      "Number of keys found by cache reads.",
+		}, labels),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "misses_total",
+			Help:      "Number of keys missing on cache reads.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "errors_total",
+			Help:      "Number of failed cache commands.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of cache commands.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, labels),
+	}
+}
+
+func (c *PrometheusCollector) Observe(ctx context.Context, event Event) {
+	if event.Hits > 0 {
+		c.hits.WithLabelValues(event.Operation, event.Prefix).Add(float64(event.Hits))
+	}
+	if event.Misses > 0 {
+		c.misses.WithLabelValues(event.Operation, event.Prefix).Add(float64(event.Misses))
+	}
+	if event.Err != nil {
+		c.errors.WithLabelValues(event.Operation, event.Prefix).Inc()
+	}
+	c.duration.WithLabelValues(event.Operation, event.Prefix).Observe(event.Duration.Seconds())
+}
+
+func (c *PrometheusCollector) Describe(descs chan<- *prometheus.Desc) {
+	c.hits.Describe(descs)
+	c.misses.Describe(descs)
+	c.errors.Describe(descs)
+	c.duration.Describe(descs)
+}
+
+func (c *PrometheusCollector) Collect(metrics chan<- prometheus.Metric) {
+	c.hits.Collect(metrics)
+	c.misses.Collect(metrics)
+	c.errors.Collect(metrics)
+	c.duration.Collect(metrics)
+}
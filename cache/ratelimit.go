@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const rateLimitPrefix = "ratelimit:"
+
+type LimitAlgorithm int
+
+const (
+	// FixedWindow counts requests in consecutive windows of Limit.Period.
+	FixedWindow LimitAlgorithm = iota
+	// SlidingWindowLog keeps the time of every allowed request and counts
+	// the ones within the last Limit.Period.
+	SlidingWindowLog
+	// GCRA spreads Limit.Rate requests evenly over Limit.Period while
+	// allowing bursts of up to Limit.Burst requests.
+	GCRA
+)
+
+func (a LimitAlgorithm) String() string {
+	switch a {
+	case FixedWindow:
+		return "fixed-window"
+	case SlidingWindowLog:
+		return "sliding-window-log"
+	case GCRA:
+		return "gcra"
+	}
+	return fmt.Sprintf("LimitAlgorithm(%d)", int(a))
+}
+
+var (
+	ErrInvalidLimit = errors.New("invalid rate limit")
+)
+
+// Limit allows Rate requests per Period, both must be positive and Period
+// at least a microsecond per request. Burst is only used by GCRA and
+// defaults to Rate.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+type LimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed,
+	// it is zero when the request was allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limiter is back to its full limit.
+	ResetAfter time.Duration
+}
+
+// Limiter decides whether the request identified by key is allowed.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (LimitResult, error)
+}
+
+var (
+	fixedWindowScript = redisClient.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+if current == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {current, redis.call("PTTL", KEYS[1])}
+`)
+	slidingWindowLogScript = redisClient.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(window / 1000))
+local retry = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if allowed == 0 and oldest[2] then
+	retry = tonumber(oldest[2]) + window - now
+end
+local reset = 0
+local newest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+end
+return {allowed, limit - count, retry, reset}
+`)
+	gcraScript = redisClient.NewScript(`
+local now = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission
+local diff = now - (new_tat - burst)
+local remaining = math.floor(diff / emission)
+if remaining < 0 then
+	return {0, 0, -diff, tat - now}
+end
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil((new_tat - now) / 1000))
+return {1, remaining, 0, new_tat - now}
+`)
+)
+
+type redisLimiter struct {
+	redis     *Redis
+	algorithm LimitAlgorithm
+	limit     Limit
+}
+
+// NewLimiter creates a limiter that keeps its state in r, so the limit is
+// shared by every instance using the same Redis. Every algorithm keeps its
+// own state, limiters with different algorithms don't share their limit.
+// It returns ErrInvalidLimit when limit can't be enforced, see Limit.
+func (r *Redis) NewLimiter(algorithm LimitAlgorithm, limit Limit) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &redisLimiter{
+		redis:     r,
+		algorithm: algorithm,
+		limit:     limit.withDefaults(),
+	}, nil
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string) (LimitResult, error) {
+	var (
+		result []interface{}
+		err    error
+		keys   = []string{l.redis.key(rateLimitPrefix + l.algorithm.String() + ":" + key)}
+		now    = time.Now().UnixNano() / int64(time.Microsecond)
+	)
+	switch l.algorithm {
+	case SlidingWindowLog:
+		var member string
+		member, err = newToken()
+		if err != nil {
+			return LimitResult{}, err
+		}
+		result, err = l.run(ctx, slidingWindowLogScript, keys,
+			now, l.limit.Period.Microseconds(), l.limit.Rate, member)
+	case GCRA:
+		emission := l.limit.emission().Microseconds()
+		result, err = l.run(ctx, gcraScript, keys,
+			now, emission, emission*int64(l.limit.Burst))
+	default:
+		result, err = l.run(ctx, fixedWindowScript, keys, l.limit.Period.Milliseconds())
+		if err == nil {
+			current, ttl := result[0].(int64), result[1].(int64)
+			return l.limit.fixedWindowResult(int(current), time.Duration(ttl)*time.Millisecond), nil
+		}
+	}
+	if err != nil {
+		return LimitResult{}, err
+	}
+
+	return LimitResult{
+		Allowed:    result[0].(int64) == 1,
+		Remaining:  int(result[1].(int64)),
+		RetryAfter: time.Duration(result[2].(int64)) * time.Microsecond,
+		ResetAfter: time.Duration(result[3].(int64)) * time.Microsecond,
+	}, nil
+}
+
+func (l *redisLimiter) run(ctx context.Context, script *redisClient.Script, keys []string, args ...interface{}) ([]interface{}, error) {
+	result, err := script.Run(ctx, l.redis.client, keys, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+	return result.([]interface{}), nil
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Period <= 0 || l.Burst < 0 {
+		return fmt.Errorf("%w: %d requests per %s", ErrInvalidLimit, l.Rate, l.Period)
+	}
+	if l.emission() < time.Microsecond {
+		return fmt.Errorf("%w: %d requests per %s exceeds a request per microsecond", ErrInvalidLimit, l.Rate, l.Period)
+	}
+	return nil
+}
+
+func (l Limit) withDefaults() Limit {
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+	return l
+}
+
+// emission is the interval between two requests when they are evenly
+// spread over the period.
+func (l Limit) emission() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+func (l Limit) fixedWindowResult(current int, resetAfter time.Duration) LimitResult {
+	result := LimitResult{
+		Allowed:    current <= l.Rate,
+		Remaining:  l.Rate - current,
+		ResetAfter: resetAfter,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if !result.Allowed {
+		result.RetryAfter = resetAfter
+	}
+	return result
+}
+
+type memoryLimiter struct {
+	mu        sync.Mutex
+	algorithm LimitAlgorithm
+	limit     Limit
+	windows   map[string]*memoryWindow
+	logs      map[string][]time.Time
+	tats      map[string]time.Time
+	now       func() time.Time
+}
+
+type memoryWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// NewMemoryLimiter creates a process-local limiter with the same behaviour
+// as the Redis one, meant for tests and single-node tools.
+func NewMemoryLimiter(algorithm LimitAlgorithm, limit Limit) (Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &memoryLimiter{
+		algorithm: algorithm,
+		limit:     limit.withDefaults(),
+		windows:   map[string]*memoryWindow{},
+		logs:      map[string][]time.Time{},
+		tats:      map[string]time.Time{},
+		now:       time.Now,
+	}, nil
+}
+
+func (l *memoryLimiter) Allow(ctx context.Context, key string) (LimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	switch l.algorithm {
+	case SlidingWindowLog:
+		return l.slidingWindowLog(key, now), nil
+	case GCRA:
+		return l.gcra(key, now), nil
+	default:
+		return l.fixedWindow(key, now), nil
+	}
+}
+
+func (l *memoryLimiter) fixedWindow(key string, now time.Time) LimitResult {
+	window, ok := l.windows[key]
+	if !ok || !now.Before(window.resetAt) {
+		window = &memoryWindow{resetAt: now.Add(l.limit.Period)}
+		l.windows[key] = window
+	}
+	window.count++
+	return l.limit.fixedWindowResult(window.count, window.resetAt.Sub(now))
+}
+
+func (l *memoryLimiter) slidingWindowLog(key string, now time.Time) LimitResult {
+	log := l.logs[key]
+	start := 0
+	for start < len(log) && !log[start].After(now.Add(-l.limit.Period)) {
+		start++
+	}
+	log = log[start:]
+
+	var result LimitResult
+	if len(log) < l.limit.Rate {
+		log = append(log, now)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = log[0].Add(l.limit.Period).Sub(now)
+	}
+	l.logs[key] = log
+
+	result.Remaining = l.limit.Rate - len(log)
+	if len(log) > 0 {
+		result.ResetAfter = log[len(log)-1].Add(l.limit.Period).Sub(now)
+	}
+	return result
+}
+
+func (l *memoryLimiter) gcra(key string, now time.Time) LimitResult {
+	emission := l.limit.emission()
+	tat, ok := l.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(emission)
+	diff := now.Sub(next.Add(-emission * time.Duration(l.limit.Burst)))
+	if diff < 0 {
+		return LimitResult{
+			RetryAfter: -diff,
+			ResetAfter: tat.Sub(now),
+		}
+	}
+	l.tats[key] = next
+	return LimitResult{
+		Allowed:    true,
+		Remaining:  int(diff / emission),
+		ResetAfter: next.Sub(now),
+	}
+}
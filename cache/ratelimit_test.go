@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	limit := Limit{Rate: 2, Period: time.Minute}
+	tests := []struct {
+		name      string
+		algorithm LimitAlgorithm
+		elapsed   []time.Duration
+		want      []LimitResult
+	}{
+		{
+			"Fixed window",
+			FixedWindow,
+			[]time.Duration{0, time.Second, 2 * time.Second, time.Minute},
+			[]LimitResult{
+				{Allowed: true, Remaining: 1, ResetAfter: time.Minute},
+				{Allowed: true, Remaining: 0, ResetAfter: 59 * time.Second},
+				{Allowed: false, Remaining: 0, RetryAfter: 58 * time.Second, ResetAfter: 58 * time.Second},
+				{Allowed: true, Remaining: 1, ResetAfter: time.Minute},
+			},
+		},
+		{
+			"Sliding window log",
+			SlidingWindowLog,
+			[]time.Duration{0, 30 * time.Second, 40 * time.Second, time.Minute + time.Second},
+			[]LimitResult{
+				{Allowed: true, Remaining: 1, ResetAfter: time.Minute},
+				{Allowed: true, Remaining: 0, ResetAfter: time.Minute},
+				{Allowed: false, Remaining: 0, RetryAfter: 20 * time.Second, ResetAfter: 50 * time.Second},
+				{Allowed: true, Remaining: 0, ResetAfter: time.Minute},
+			},
+		},
+		{
+			"GCRA",
+			GCRA,
+			[]time.Duration{0, 0, 0, 30 * time.Second},
+			[]LimitResult{
+				{Allowed: true, Remaining: 1, ResetAfter: 30 * time.Second},
+				{Allowed: true, Remaining: 0, ResetAfter: time.Minute},
+				{Allowed: false, Remaining: 0, RetryAfter: 30 * time.Second, ResetAfter: time.Minute},
+				{Allowed: true, Remaining: 0, ResetAfter: time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			limiter, err := NewMemoryLimiter(tt.algorithm, limit)
+			require.NoError(t, err)
+			l := limiter.(*memoryLimiter)
+			for i, elapsed := range tt.elapsed {
+				l.now = func() time.Time { return start.Add(elapsed) }
+				got, err := l.Allow(context.Background(), "user")
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want[i], got, "request %d", i)
+			}
+		})
+	}
+}
+
+func TestNewLimiter_InvalidLimit(t *testing.T) {
+	_, r := newTestRedis(t)
+	for _, limit := range []Limit{
+		{},
+		{Rate: 0, Period: time.Minute},
+		{Rate: 10, Period: 0},
+		{Rate: 10, Period: time.Minute, Burst: -1},
+		{Rate: 1000, Period: time.Millisecond / 2},
+	} {
+		_, err := r.NewLimiter(GCRA, limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit, "%+v", limit)
+		_, err = NewMemoryLimiter(GCRA, limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit, "%+v", limit)
+	}
+}
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Period: time.Minute}
+
+	for _, algorithm := range []LimitAlgorithm{FixedWindow, SlidingWindowLog, GCRA} {
+		l, err := r.NewLimiter(algorithm, limit)
+		require.NoError(t, err)
+		for i, allowed := range []bool{true, true, false} {
+			got, err := l.Allow(ctx, "user")
+			require.NoError(t, err, "%s request %d", algorithm, i)
+			assert.Equal(t, allowed, got.Allowed, "%s request %d", algorithm, i)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{
+		"ratelimit:fixed-window:user",
+		"ratelimit:sliding-window-log:user",
+		"ratelimit:gcra:user",
+	}, server.Keys())
+}
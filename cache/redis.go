@@ -2,76 +2,241 @@ package cache
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	redisClient "github.com/go-redis/redis/v8"
 	"github.com/spf13/viper"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"sort"
 	"time"
 )
 
-var (
-	ErrRetrieve  = errors.New("unable to retrieve")
-	ErrStore     = errors.New("unable to store")
-	ErrSetExpiry = errors.New("unable to set expiry")
-)
-
 type Redis struct {
-	client *redisClient.Client
+	client      redisClient.UniversalClient
+	group       *singleflight.Group
+	codec       Codec
+	prefix      string
+	compression Compression
+	breaker     *CircuitBreaker
+	jitter      Jitter
+	sliding     time.Duration
 }
 
 func NewRedis(uri, port, password string) Redis {
+	return newRedis(redisClient.NewClient(&redisClient.Options{
+		Addr:     fmt.Sprintf("%s:%s", uri, port),
+		Password: password,
+	}))
+}
+
+// NewUniversalRedis creates a standalone, sentinel or cluster client
+// depending on options.
+func NewUniversalRedis(options RedisOptions) Redis {
+	return newRedis(options.Client()).WithNamespace(options.Namespace)
+}
+
+// NewDefaultRedis reads its settings from viper, see DefaultRedisOptions.
+// When they can't be parsed the error is logged and the client falls back
+// to REDIS_URI, REDIS_PORT and REDIS_PASSWORD.
+//
+// Deprecated: use NewDefaultRedisE, which returns the error.
+func NewDefaultRedis() Redis {
+	r, err := NewDefaultRedisE()
+	if err != nil {
+		log.Printf("cache: invalid redis settings, falling back to REDIS_URI and REDIS_PORT: %v", err)
+		return NewRedis(viper.GetString("REDIS_URI"), viper.GetString("REDIS_PORT"), viper.GetString("REDIS_PASSWORD"))
+	}
+	return r
+}
+
+// NewDefaultRedisE reads its settings from viper, see DefaultRedisOptions,
+// and returns the error of settings that can't be parsed.
+func NewDefaultRedisE() (Redis, error) {
+	options, err := DefaultRedisOptions()
+	if err != nil {
+		return Redis{}, err
+	}
+	return NewUniversalRedis(options), nil
+}
+
+func newRedis(client redisClient.UniversalClient) Redis {
 	return Redis{
-		redisClient.NewClient(&redisClient.Options{
-			Addr:     fmt.Sprintf("%s:%s", uri, port),
-			Password: password,
-		}),
+		client: client,
+		group:  &singleflight.Group{},
+		codec:  JSONCodec,
 	}
 }
-func NewDefaultRedis() Redis {
-	uri := viper.GetString("REDIS_URI")
-	port := viper.GetString("REDIS_PORT")
-	password := viper.GetString("REDIS_PASSWORD")
-	return NewRedis(uri, port, password)
+
+// WithNamespace returns a copy of r that prefixes every key with namespace,
+// so services sharing one Redis don't overwrite each other's keys.
+// Namespaces nest, an empty namespace returns r unchanged.
+func (r Redis) WithNamespace(namespace string) Redis {
+	if namespace != "" {
+		r.prefix += namespace + ":"
+	}
+	return r
 }
 
 func (r *Redis) Store(ctx context.Context, values map[string]string) error {
-	err := r.client.MSet(ctx, values).Err()
+	prefixed := make(map[string]string, len(values))
+	for key, value := range values {
+		value, err := r.compression.compress(value)
+		if err != nil {
+			return newError("store", ErrStore, err, key)
+		}
+		prefixed[r.key(key)] = value
+	}
+	err := r.client.MSet(ctx, prefixed).Err()
+	if r.failOpen(err) {
+		return nil
+	}
 	if err != nil {
-		return ErrStore
+		return newError("store", ErrStore, err, keysOf(values)...)
 	}
 	return nil
 }
 
+// Item is a value written by StoreItems together with its own expiry and
+// tags. A zero Expiration stores the value without a TTL.
+type Item struct {
+	Value      string
+	Expiration time.Duration
+	Tags       []string
+}
+
 func (r *Redis) StoreWithExpire(ctx context.Context, values map[string]string, duration time.Duration) error {
-	err := r.Store(ctx, values)
-	if err != nil {
-		return err
+	items := make(map[string]Item, len(values))
+	for key, value := range values {
+		items[key] = Item{Value: value, Expiration: duration}
 	}
-	for key, _ := range values {
-		err := r.client.Expire(ctx, key, duration).Err()
+	return r.StoreItems(ctx, items)
+}
+
+// StoreItems writes every item and its expiry in a single MULTI/EXEC
+// transaction, so either all keys are stored with their TTL or none are.
+func (r *Redis) StoreItems(ctx context.Context, items map[string]Item) error {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The jitter is applied before the tags are queued so a tag set lives
+	// as long as the keys it holds.
+	stored := make(map[string]Item, len(items))
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		item := items[key]
+		value, err := r.compression.compress(item.Value)
 		if err != nil {
-			return ErrSetExpiry
+			return newError("store", ErrStore, err, key)
 		}
+		values[i] = value
+		item.Expiration = r.expiration(item.Expiration)
+		stored[key] = item
 	}
 
+	// owners holds the keys written by every queued command, a failed tag
+	// update is reported for the keys carrying the tag.
+	owners := make([][]string, len(keys))
+	cmds, err := r.client.TxPipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		for i, key := range keys {
+			pipe.Set(ctx, r.key(key), values[i], stored[key].Expiration)
+			owners[i] = []string{key}
+		}
+		owners = append(owners, r.queueTags(ctx, pipe, keys, stored)...)
+		return nil
+	})
+	if r.failOpen(err) {
+		return nil
+	}
+	if err != nil {
+		failed := failedOwners(owners, cmds)
+		if len(failed) == 0 {
+			failed = keys
+		}
+		return newError("store", ErrStore, err, failed...)
+	}
 	return nil
 }
 
+// Get returns the value of every key, an empty string for missing ones.
+// With a sliding expiration the TTL of the keys that expire is reset as well.
 func (r *Redis) Get(ctx context.Context, keys ...string) (map[string]string, error) {
-	result, err := r.client.MGet(ctx, keys...).Result()
-
+	result, err := r.mget(ctx, keys)
+	if r.failOpen(err) {
+		result, err = make([]interface{}, len(keys)), nil
+	}
 	if err != nil {
-		return nil, ErrRetrieve
+		return nil, newError("get", ErrRetrieve, err, keys...)
 	}
 
 	data := map[string]string{}
 	for i, key := range keys {
 		s, ok := result[i].(string)
-		if ok {
-			data[key] = s
-		} else {
+		if !ok {
 			data[key] = ""
+			continue
+		}
+		if data[key], err = decompress(s); err != nil {
+			return nil, newError("get", ErrRetrieve, err, key)
 		}
 	}
 	return data, nil
 }
+
+// key returns the Redis key of key within the namespace of r.
+func (r *Redis) key(key string) string {
+	return r.prefix + key
+}
+
+func (r *Redis) keys(keys []string) []string {
+	if r.prefix == "" {
+		return keys
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.key(key)
+	}
+	return prefixed
+}
+
+func keysOf(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// failedOwners returns the keys of the commands in cmds that reported an
+// error, owners holding the keys of every command in the same order.
+func failedOwners(owners [][]string, cmds []redisClient.Cmder) []string {
+	seen := map[string]bool{}
+	var failed []string
+	for i, cmd := range cmds {
+		if i >= len(owners) || cmd.Err() == nil {
+			continue
+		}
+		for _, key := range owners[i] {
+			if !seen[key] {
+				seen[key] = true
+				failed = append(failed, key)
+			}
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// failedKeys returns the keys whose command in cmds reported an error,
+// assuming cmds were queued in the same order as keys.
+func failedKeys(keys []string, cmds []redisClient.Cmder) []string {
+	var failed []string
+	for i, cmd := range cmds {
+		if i < len(keys) && cmd.Err() != nil {
+			failed = append(failed, keys[i])
+		}
+	}
+	return failed
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis returns a Redis backed by an in-memory server stopped at the
+// end of the test.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, Redis) {
+	server := miniredis.RunT(t)
+	return server, NewRedis(server.Host(), server.Port(), "")
+}
+
+// pipelineRecorder records the commands of every pipeline.
+type pipelineRecorder struct {
+	pipelines [][]string
+}
+
+func (h *pipelineRecorder) BeforeProcess(ctx context.Context, cmd redisClient.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *pipelineRecorder) AfterProcess(ctx context.Context, cmd redisClient.Cmder) error {
+	return nil
+}
+
+func (h *pipelineRecorder) BeforeProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	h.pipelines = append(h.pipelines, names)
+	return ctx, nil
+}
+
+func (h *pipelineRecorder) AfterProcessPipeline(ctx context.Context, cmds []redisClient.Cmder) error {
+	return nil
+}
+
+func TestRedis_StoreItems(t *testing.T) {
+	server, r := newTestRedis(t)
+	recorder := &pipelineRecorder{}
+	r.client.AddHook(recorder)
+
+	err := r.StoreItems(context.Background(), map[string]Item{
+		"a": {Value: "1", Expiration: time.Minute, Tags: []string{"shared"}},
+		"b": {Value: "2", Expiration: time.Hour, Tags: []string{"shared", "own"}},
+		"c": {Value: "3"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Minute, server.TTL("a"))
+	assert.Equal(t, time.Hour, server.TTL("b"))
+	assert.Equal(t, time.Duration(0), server.TTL("c"))
+	assert.True(t, server.Exists("c"))
+
+	members, err := server.Members(tagPrefix + "shared")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+	assert.Equal(t, time.Hour, server.TTL(tagPrefix+"shared"), "a tag set lives as long as its longest key")
+	members, err = server.Members(tagPrefix + "own")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, members)
+
+	// Every write goes through a single transaction.
+	require.Len(t, recorder.pipelines, 1)
+	pipeline := recorder.pipelines[0]
+	assert.Equal(t, "multi", pipeline[0])
+	assert.Equal(t, "exec", pipeline[len(pipeline)-1])
+	assert.Equal(t, []string{"set", "set", "set"}, pipeline[1:4])
+}
+
+func TestRedis_StoreItems_PartialFailure(t *testing.T) {
+	server, r := newTestRedis(t)
+	// The tag update of b fails inside the transaction with WRONGTYPE.
+	require.NoError(t, server.Set(tagPrefix+"own", "not a set"))
+
+	err := r.StoreItems(context.Background(), map[string]Item{
+		"a": {Value: "1", Tags: []string{"shared"}},
+		"b": {Value: "2", Tags: []string{"shared", "own"}},
+		"c": {Value: "3"},
+	})
+	require.ErrorIs(t, err, ErrStore)
+	var cacheErr *Error
+	require.True(t, errors.As(err, &cacheErr))
+	assert.Equal(t, []string{"b"}, cacheErr.Keys)
+}
+
+func TestFailedOwners(t *testing.T) {
+	ctx := context.Background()
+	failed := redisClient.NewCmd(ctx, "evalsha")
+	failed.SetErr(errors.New("WRONGTYPE"))
+	cmds := []redisClient.Cmder{
+		redisClient.NewStatusCmd(ctx, "set"),
+		failed,
+		failed,
+	}
+	owners := [][]string{{"a"}, {"c", "b"}, {"b"}}
+	assert.Equal(t, []string{"b", "c"}, failedOwners(owners, cmds))
+	assert.Nil(t, failedOwners(owners, cmds[:1]))
+}
@@ -0,0 +1,472 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	sessionPrefix            = "session:"
+	sessionUserPrefix        = "session:user:"
+	defaultSessionCookie     = "session_id"
+	defaultSessionExpiration = 24 * time.Hour
+	sessionIDLength          = 32
+)
+
+type SessionOptions struct {
+	// CookieName defaults to "session_id".
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	Secure       bool
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// Expiration is how long a session lives without being used, every
+	// request using it starts the period again. It defaults to a day.
+	Expiration time.Duration
+	// ErrorHandler responds when the middleware can't load or save a
+	// session, it defaults to a 500 Internal Server Error.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+var (
+	// saveSessionScript writes the session KEYS[1] and moves it in the user
+	// indexes, KEYS[3] of its previous user and KEYS[4] of its user. KEYS[2]
+	// is the key it was stored under, it is removed when it differs. A
+	// stored session, ARGV[5] set, is only written while KEYS[2] still
+	// exists so a revoked or destroyed session isn't brought back.
+	saveSessionScript = redisClient.NewScript(`
+if ARGV[5] == "1" and redis.call("EXISTS", KEYS[2]) == 0 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+if KEYS[2] ~= KEYS[1] then
+	redis.call("DEL", KEYS[2])
+end
+if ARGV[6] == "1" then
+	redis.call("SREM", KEYS[3], ARGV[3], ARGV[4])
+end
+if ARGV[7] == "1" then
+	redis.call("SREM", KEYS[4], ARGV[4])
+	redis.call("SADD", KEYS[4], ARGV[3])
+	redis.call("PEXPIRE", KEYS[4], ARGV[2])
+end
+return 1
+`)
+)
+
+// Session is the server side state of a client, identified by the random
+// ID of its cookie.
+type Session struct {
+	ID        string            `json:"-"`
+	UserID    string            `json:"user_id,omitempty"`
+	Values    map[string]string `json:"values,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	previousUserID string
+	modified       bool
+	stored         bool
+	// issued is set while the client doesn't know ID yet.
+	issued    bool
+	destroyed bool
+}
+
+func (s *Session) Get(key string) string {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key, value string) {
+	if s.Values == nil {
+		s.Values = map[string]string{}
+	}
+	s.Values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.modified = true
+}
+
+// SetUser attaches the session to userID, so RevokeUser ends it. Call
+// SessionManager.Rotate as well when the user logs in.
+func (s *Session) SetUser(userID string) {
+	if s.UserID == userID {
+		return
+	}
+	if s.previousUserID == "" {
+		s.previousUserID = s.UserID
+	}
+	s.UserID = userID
+	s.modified = true
+}
+
+// SessionManager stores sessions in Redis and keeps an index of the
+// sessions of every user.
+type SessionManager struct {
+	redis   *Redis
+	options SessionOptions
+}
+
+type sessionContextKey struct{}
+
+func (r *Redis) NewSessionManager(options SessionOptions) *SessionManager {
+	if options.CookieName == "" {
+		options.CookieName = defaultSessionCookie
+	}
+	if options.CookiePath == "" {
+		options.CookiePath = "/"
+	}
+	if options.SameSite == 0 {
+		options.SameSite = http.SameSiteLaxMode
+	}
+	if options.Expiration <= 0 {
+		options.Expiration = defaultSessionExpiration
+	}
+	if options.ErrorHandler == nil {
+		options.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+	return &SessionManager{
+		redis:   r,
+		options: options,
+	}
+}
+
+// New returns an empty session with a fresh ID, it is stored by Save.
+func (m *SessionManager) New() (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		ID:        id,
+		CreatedAt: time.Now(),
+		issued:    true,
+	}, nil
+}
+
+// Load returns the session id and restarts its expiration. It returns
+// ErrMiss when the session does not exist or expired.
+func (m *SessionManager) Load(ctx context.Context, id string) (*Session, error) {
+	var data *redisClient.StringCmd
+	_, err := m.redis.client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		data = pipe.Get(ctx, m.key(id))
+		pipe.PExpire(ctx, m.key(id), m.options.Expiration)
+		return nil
+	})
+	if err == redisClient.Nil {
+		return nil, newError("session load", ErrMiss, nil, id)
+	}
+	if err != nil {
+		return nil, newError("session load", ErrRetrieve, err, id)
+	}
+
+	session := &Session{ID: id, stored: true}
+	if err := json.Unmarshal([]byte(data.Val()), session); err != nil {
+		return nil, newError("session load", ErrRetrieve, err, id)
+	}
+	if session.UserID != "" {
+		err := m.redis.client.PExpire(ctx, m.userKey(session.UserID), m.options.Expiration).Err()
+		if err != nil {
+			return nil, newError("session load", ErrSetExpiry, err, id)
+		}
+	}
+	return session, nil
+}
+
+// Save stores session and restarts its expiration. It returns ErrMiss when
+// the session was stored before but revoked, destroyed or expired since.
+func (m *SessionManager) Save(ctx context.Context, session *Session) error {
+	if err := m.save(ctx, session, ""); err != nil {
+		return m.saveError("session save", err, session.ID)
+	}
+	return nil
+}
+
+// Rotate stores session under a new ID and removes the old one. Call it
+// whenever the privileges of the session change, such as on login, so an
+// ID leaked before can't be used anymore.
+func (m *SessionManager) Rotate(ctx context.Context, session *Session) error {
+	id, err := newSessionID()
+	if err != nil {
+		return newError("session rotate", ErrStore, err, session.ID)
+	}
+
+	previous := session.ID
+	session.ID = id
+	if err := m.save(ctx, session, previous); err != nil {
+		session.ID = previous
+		return m.saveError("session rotate", err, previous)
+	}
+	session.issued = true
+	return nil
+}
+
+// Destroy removes session, for example on logout.
+func (m *SessionManager) Destroy(ctx context.Context, session *Session) error {
+	_, err := m.redis.client.TxPipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		pipe.Del(ctx, m.key(session.ID))
+		if session.UserID != "" {
+			pipe.SRem(ctx, m.userKey(session.UserID), session.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return newError("session destroy", ErrDelete, err, session.ID)
+	}
+	session.destroyed = true
+	return nil
+}
+
+// RevokeUser removes every session of userID and returns how many there
+// were, for example after a password change.
+func (m *SessionManager) RevokeUser(ctx context.Context, userID string) (int64, error) {
+	client := m.redis.client
+	ids, err := client.SMembers(ctx, m.userKey(userID)).Result()
+	if err != nil {
+		return 0, newError("session revoke", ErrRetrieve, err, userID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// The removed IDs are taken out of the index rather than deleting it,
+	// so a session created meanwhile stays revocable.
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	deletes := make([]*redisClient.IntCmd, len(ids))
+	_, err = client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		for i, id := range ids {
+			deletes[i] = pipe.Del(ctx, m.key(id))
+		}
+		pipe.SRem(ctx, m.userKey(userID), members...)
+		return nil
+	})
+	if err != nil {
+		return 0, newError("session revoke", ErrDelete, err, userID)
+	}
+
+	var deleted int64
+	for _, cmd := range deletes {
+		deleted += cmd.Val()
+	}
+	return deleted, nil
+}
+
+// Middleware loads the session of the request cookie, or starts a new one,
+// into the request context, see SessionFromContext. The session is saved
+// and its cookie set before the response headers are written, changes made
+// afterwards are lost. A new session is only stored once it is modified.
+func (m *SessionManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := m.loadRequest(r)
+		if err != nil {
+			m.options.ErrorHandler(w, r, err)
+			return
+		}
+
+		writer := &sessionWriter{
+			ResponseWriter: w,
+			manager:        m,
+			request:        r,
+			session:        session,
+		}
+		next.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session)))
+		writer.commit()
+	})
+}
+
+// SessionFromContext returns the session loaded by Middleware, nil outside
+// of it.
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return session
+}
+
+func (m *SessionManager) loadRequest(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.options.CookieName)
+	if err != nil || cookie.Value == "" {
+		return m.New()
+	}
+	session, err := m.Load(r.Context(), cookie.Value)
+	if err == nil || !errors.Is(err, ErrMiss) {
+		return session, err
+	}
+	return m.New()
+}
+
+// save writes session and moves it in the user index, previous is the ID
+// it replaces, if any. It returns ErrMiss when a stored session no longer
+// exists.
+func (m *SessionManager) save(ctx context.Context, session *Session, previous string) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	stored := m.key(session.ID)
+	if previous != "" {
+		stored = m.key(previous)
+	}
+	keys := []string{
+		m.key(session.ID),
+		stored,
+		m.userKey(session.previousUserID),
+		m.userKey(session.UserID),
+	}
+	saved, err := saveSessionScript.Run(ctx, m.redis.client, keys,
+		data,
+		m.options.Expiration.Milliseconds(),
+		session.ID,
+		previous,
+		flag(session.stored),
+		flag(session.previousUserID != ""),
+		flag(session.UserID != ""),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if saved == 0 {
+		return ErrMiss
+	}
+	session.previousUserID = ""
+	session.modified = false
+	session.stored = true
+	return nil
+}
+
+func (m *SessionManager) saveError(op string, err error, id string) error {
+	if err == ErrMiss {
+		return newError(op, ErrMiss, nil, id)
+	}
+	return newError(op, ErrStore, err, id)
+}
+
+func (m *SessionManager) key(id string) string {
+	return m.redis.key(sessionPrefix + id)
+}
+
+func (m *SessionManager) userKey(userID string) string {
+	return m.redis.key(sessionUserPrefix + userID)
+}
+
+func (m *SessionManager) cookie(session *Session) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     m.options.CookieName,
+		Value:    session.ID,
+		Path:     m.options.CookiePath,
+		Domain:   m.options.CookieDomain,
+		Secure:   m.options.Secure,
+		HttpOnly: true,
+		SameSite: m.options.SameSite,
+	}
+	if session.destroyed {
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// sessionWriter saves the session before the first byte of the response is
+// written, while the cookie can still be set.
+type sessionWriter struct {
+	http.ResponseWriter
+	manager   *SessionManager
+	request   *http.Request
+	session   *Session
+	committed bool
+	failed    bool
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commit()
+	if !w.failed {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.commit()
+	if w.failed {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush saves the session, as the headers are written, and flushes the
+// response when the underlying writer supports it.
+func (w *sessionWriter) Flush() {
+	w.commit()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok && !w.failed {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection when the underlying writer supports it.
+// The session is still saved once the handler returns but its cookie can't
+// be set anymore.
+func (w *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// commit saves the session once it changed and sets the cookie of a new
+// ID. When the session can't be saved the error handler responds instead
+// and the rest of the response is dropped.
+func (w *sessionWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	session := w.session
+	if session.destroyed {
+		http.SetCookie(w.ResponseWriter, w.manager.cookie(session))
+		return
+	}
+	if session.modified {
+		if err := w.manager.Save(w.request.Context(), session); err != nil {
+			w.failed = true
+			w.manager.options.ErrorHandler(w.ResponseWriter, w.request, err)
+			return
+		}
+	}
+	if session.issued && session.stored {
+		session.issued = false
+		http.SetCookie(w.ResponseWriter, w.manager.cookie(session))
+	}
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, sessionIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
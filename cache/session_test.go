@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_SetUser(t *testing.T) {
+	session := &Session{UserID: "1"}
+
+	session.SetUser("1")
+	assert.False(t, session.modified)
+
+	session.SetUser("2")
+	session.SetUser("3")
+	assert.True(t, session.modified)
+	assert.Equal(t, "3", session.UserID)
+	assert.Equal(t, "1", session.previousUserID, "the stored owner is kept until saved")
+}
+
+func TestSessionManager_cookie(t *testing.T) {
+	manager := (&Redis{}).NewSessionManager(SessionOptions{Secure: true})
+
+	cookie := manager.cookie(&Session{ID: "abc"})
+	assert.Equal(t, "session_id", cookie.Name)
+	assert.Equal(t, "abc", cookie.Value)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	cookie = manager.cookie(&Session{ID: "abc", destroyed: true})
+	assert.Empty(t, cookie.Value)
+	assert.Equal(t, -1, cookie.MaxAge)
+}
+
+func TestNewSessionID(t *testing.T) {
+	id, err := newSessionID()
+	assert.NoError(t, err)
+	assert.Len(t, id, 43)
+
+	other, err := newSessionID()
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, other)
+}
+
+func TestSessionManager_Middleware(t *testing.T) {
+	server, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := SessionFromContext(r.Context())
+		if r.Method == http.MethodPost {
+			session.Set("cart", "1")
+		}
+		_, _ = w.Write([]byte(session.Get("cart")))
+	}))
+
+	// A session that isn't modified is neither stored nor sent.
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, recorder.Result().Cookies())
+	assert.Empty(t, server.Keys())
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "session_id", cookies[0].Name)
+	assert.True(t, server.Exists(sessionPrefix+cookies[0].Value))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, "1", recorder.Body.String())
+	assert.Empty(t, recorder.Result().Cookies(), "a known session isn't sent again")
+}
+
+func TestSessionManager_Middleware_SaveError(t *testing.T) {
+	server, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SessionFromContext(r.Context()).Set("cart", "1")
+		server.Close()
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "ok")
+	assert.Empty(t, recorder.Result().Cookies())
+}
+
+func TestSessionManager_Middleware_Flush(t *testing.T) {
+	_, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SessionFromContext(r.Context()).Set("cart", "1")
+		flusher, ok := w.(http.Flusher)
+		require.True(t, ok)
+		flusher.Flush()
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, recorder.Flushed)
+	assert.Len(t, recorder.Result().Cookies(), 1)
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestSessionManager_Middleware_Hijack(t *testing.T) {
+	_, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	var hijackErr error
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		require.True(t, ok)
+		_, _, hijackErr = hijacker.Hijack()
+	}))
+
+	recorder := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NoError(t, hijackErr)
+	assert.True(t, recorder.hijacked)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, hijackErr, http.ErrNotSupported)
+}
+
+func TestSessionManager_Load(t *testing.T) {
+	_, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	ctx := context.Background()
+
+	_, err := manager.Load(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	session, err := manager.New()
+	require.NoError(t, err)
+	session.SetUser("1")
+	require.NoError(t, manager.Save(ctx, session))
+
+	loaded, err := manager.Load(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "1", loaded.UserID)
+
+	revoked, err := manager.RevokeUser(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+	_, err = manager.Load(ctx, session.ID)
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+func TestSessionManager_Save_Revoked(t *testing.T) {
+	server, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	ctx := context.Background()
+
+	session, err := manager.New()
+	require.NoError(t, err)
+	session.SetUser("u1")
+	require.NoError(t, manager.Save(ctx, session))
+
+	inFlight, err := manager.Load(ctx, session.ID)
+	require.NoError(t, err)
+	revoked, err := manager.RevokeUser(ctx, "u1")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+
+	inFlight.Set("cart", "1")
+	assert.ErrorIs(t, manager.Save(ctx, inFlight), ErrMiss)
+	assert.ErrorIs(t, manager.Rotate(ctx, inFlight), ErrMiss)
+	_, err = manager.Load(ctx, session.ID)
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.False(t, server.Exists(sessionUserPrefix+"u1"))
+	assert.Empty(t, server.Keys())
+}
+
+func TestSessionManager_Rotate(t *testing.T) {
+	server, r := newTestRedis(t)
+	manager := r.NewSessionManager(SessionOptions{})
+	ctx := context.Background()
+
+	session, err := manager.New()
+	require.NoError(t, err)
+	session.SetUser("u1")
+	require.NoError(t, manager.Save(ctx, session))
+	previous := session.ID
+
+	session.SetUser("u2")
+	require.NoError(t, manager.Rotate(ctx, session))
+	assert.NotEqual(t, previous, session.ID)
+	assert.False(t, server.Exists(sessionPrefix+previous))
+	assert.True(t, server.Exists(sessionPrefix+session.ID))
+	assert.False(t, server.Exists(sessionUserPrefix+"u1"))
+	members, err := server.Members(sessionUserPrefix + "u2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{session.ID}, members)
+}
@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultStreamBatchSize = 10
+	defaultStreamBlock     = 5 * time.Second
+	deadLetterSuffix       = ":dead"
+	ackTimeout             = 5 * time.Second
+)
+
+type StreamMessage struct {
+	ID     string
+	Values map[string]interface{}
+	// Deliveries is how many times the message was handed to a consumer,
+	// including this time.
+	Deliveries int64
+}
+
+// StreamHandler processes a message. The message is acknowledged when it
+// returns nil and stays pending otherwise. A pending message is retried when
+// the consumer restarts, or by any consumer once it stayed idle for
+// ClaimIdle.
+type StreamHandler func(ctx context.Context, message StreamMessage) error
+
+type ConsumerOptions struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// StartID is where a new group starts reading, it defaults to "$", only
+	// messages added after the group was created.
+	StartID string
+	// BatchSize is the number of messages read at once, it defaults to 10.
+	BatchSize int64
+	// Block is how long a read waits for new messages, it defaults to five
+	// seconds and bounds how long Run takes to return once stopped.
+	Block time.Duration
+	// ClaimIdle reclaims messages left pending for longer by crashed
+	// consumers, zero disables reclaiming.
+	ClaimIdle time.Duration
+	// MaxDeliveries moves a message to the dead-letter stream once it was
+	// delivered more often, zero retries forever.
+	MaxDeliveries int64
+	// DeadLetterStream defaults to the stream name with a ":dead" suffix.
+	DeadLetterStream string
+}
+
+// Consumer reads a stream as a member of a consumer group.
+type Consumer struct {
+	redis   *Redis
+	options ConsumerOptions
+	handler StreamHandler
+}
+
+// StreamAdd appends values to stream and returns the ID of the new entry.
+// A positive maxLen approximately trims the stream to that many entries.
+func (r *Redis) StreamAdd(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	id, err := r.client.XAdd(ctx, &redisClient.XAddArgs{
+		Stream: r.key(stream),
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: values,
+	}).Result()
+	if err != nil {
+		return "", newError("xadd", ErrStore, err, stream)
+	}
+	return id, nil
+}
+
+func (r *Redis) NewConsumer(options ConsumerOptions, handler StreamHandler) *Consumer {
+	if options.StartID == "" {
+		options.StartID = "$"
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultStreamBatchSize
+	}
+	if options.Block <= 0 {
+		options.Block = defaultStreamBlock
+	}
+	if options.DeadLetterStream == "" {
+		options.DeadLetterStream = options.Stream + deadLetterSuffix
+	}
+	options.Stream = r.key(options.Stream)
+	options.DeadLetterStream = r.key(options.DeadLetterStream)
+
+	return &Consumer{
+		redis:   r,
+		options: options,
+		handler: handler,
+	}
+}
+
+// Run creates the consumer group if needed and handles messages until ctx
+// is done. It first retries the messages left pending for this consumer,
+// then reads new ones and periodically reclaims idle ones. Once ctx is done
+// it returns nil after the batch in progress is handled.
+func (c *Consumer) Run(ctx context.Context) error {
+	client := c.redis.client
+	err := client.XGroupCreateMkStream(ctx, c.options.Stream, c.options.Group, c.options.StartID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	if err := c.retryPending(ctx); err != nil {
+		return err
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if c.options.ClaimIdle > 0 && time.Since(lastClaim) >= c.options.ClaimIdle {
+			if err := c.claim(ctx); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+		if _, err := c.read(ctx, ">"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryPending handles the messages delivered to this consumer before a
+// restart, which are still pending.
+func (c *Consumer) retryPending(ctx context.Context) error {
+	id := "0"
+	for ctx.Err() == nil {
+		last, err := c.read(ctx, id)
+		if err != nil || last == "" {
+			return err
+		}
+		id = last
+	}
+	return nil
+}
+
+// read handles a batch of messages after id, ">" meaning new messages, and
+// returns the ID of the last one.
+func (c *Consumer) read(ctx context.Context, id string) (string, error) {
+	streams, err := c.redis.client.XReadGroup(ctx, &redisClient.XReadGroupArgs{
+		Group:    c.options.Group,
+		Consumer: c.options.Consumer,
+		Streams:  []string{c.options.Stream, id},
+		Count:    c.options.BatchSize,
+		Block:    c.options.Block,
+	}).Result()
+	if err == redisClient.Nil || ctx.Err() != nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var last string
+	for _, stream := range streams {
+		if len(stream.Messages) == 0 {
+			continue
+		}
+		deliveries := map[string]int64{}
+		if id != ">" {
+			deliveries, err = c.deliveries(ctx, stream.Messages)
+			if err != nil {
+				return "", err
+			}
+		}
+		if err := c.handle(ctx, stream.Messages, deliveries); err != nil {
+			return "", err
+		}
+		last = stream.Messages[len(stream.Messages)-1].ID
+	}
+	return last, nil
+}
+
+// claim takes over the messages that stayed pending for longer than
+// ClaimIdle, whichever consumer they were delivered to.
+func (c *Consumer) claim(ctx context.Context) error {
+	start := "0-0"
+	for {
+		messages, next, err := c.autoClaim(ctx, start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if len(messages) > 0 {
+			deliveries, err := c.deliveries(ctx, messages)
+			if err != nil {
+				return err
+			}
+			if err := c.handle(ctx, messages, deliveries); err != nil {
+				return err
+			}
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// autoClaim runs XAUTOCLAIM from start and returns the claimed messages
+// and the cursor of the next call. The command is sent as is because the
+// client only understands the two element reply of Redis 6.2, Redis 7 adds
+// the IDs of deleted entries as a third element.
+func (c *Consumer) autoClaim(ctx context.Context, start string) ([]redisClient.XMessage, string, error) {
+	reply, err := c.redis.client.Do(ctx, "XAUTOCLAIM",
+		c.options.Stream,
+		c.options.Group,
+		c.options.Consumer,
+		c.options.ClaimIdle.Milliseconds(),
+		start,
+		"COUNT", c.options.BatchSize,
+	).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields) < 2 {
+		return nil, "", fmt.Errorf("xautoclaim: unexpected reply %v", reply)
+	}
+
+	next, _ := fields[0].(string)
+	entries, _ := fields[1].([]interface{})
+	messages := make([]redisClient.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		entry, ok := entry.([]interface{})
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		message := redisClient.XMessage{}
+		message.ID, _ = entry[0].(string)
+		if values, ok := entry[1].([]interface{}); ok {
+			message.Values = make(map[string]interface{}, len(values)/2)
+			for i := 0; i+1 < len(values); i += 2 {
+				key, _ := values[i].(string)
+				message.Values[key] = values[i+1]
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, next, nil
+}
+
+// deliveries returns the delivery count of every pending message. Each
+// message is looked up on its own, a range would also cover the other
+// entries pending for this consumer between them.
+func (c *Consumer) deliveries(ctx context.Context, messages []redisClient.XMessage) (map[string]int64, error) {
+	cmds := make([]*redisClient.XPendingExtCmd, len(messages))
+	_, err := c.redis.client.Pipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		for i, message := range messages {
+			cmds[i] = pipe.XPendingExt(ctx, &redisClient.XPendingExtArgs{
+				Stream:   c.options.Stream,
+				Group:    c.options.Group,
+				Start:    message.ID,
+				End:      message.ID,
+				Count:    1,
+				Consumer: c.options.Consumer,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make(map[string]int64, len(messages))
+	for _, cmd := range cmds {
+		for _, entry := range cmd.Val() {
+			deliveries[entry.ID] = entry.RetryCount
+		}
+	}
+	return deliveries, nil
+}
+
+func (c *Consumer) handle(ctx context.Context, messages []redisClient.XMessage, deliveries map[string]int64) error {
+	for _, message := range messages {
+		// A reclaimed entry that was deleted from the stream has no values.
+		if message.Values == nil {
+			if err := c.ack(message.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		count, ok := deliveries[message.ID]
+		if !ok {
+			count = 1
+		}
+		if c.options.MaxDeliveries > 0 && count > c.options.MaxDeliveries {
+			if err := c.deadLetter(message, count); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := c.handler(ctx, StreamMessage{
+			ID:         message.ID,
+			Values:     message.Values,
+			Deliveries: count,
+		})
+		if err != nil {
+			continue
+		}
+		if err := c.ack(message.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetter copies message to the dead-letter stream along with its
+// original ID and delivery count, then acknowledges it.
+func (c *Consumer) deadLetter(message redisClient.XMessage, deliveries int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+
+	values := make(map[string]interface{}, len(message.Values)+2)
+	for key, value := range message.Values {
+		values[key] = value
+	}
+	values["dead_letter_id"] = message.ID
+	values["dead_letter_deliveries"] = deliveries
+
+	err := c.redis.client.XAdd(ctx, &redisClient.XAddArgs{
+		Stream: c.options.DeadLetterStream,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return c.redis.client.XAck(ctx, c.options.Stream, c.options.Group, message.ID).Err()
+}
+
+// ack uses its own context so a message handled right before shutdown is
+// still acknowledged.
+func (c *Consumer) ack(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+	return c.redis.client.XAck(ctx, c.options.Stream, c.options.Group, id).Err()
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConsumer(t *testing.T, options ConsumerOptions, handler StreamHandler) (Redis, *Consumer) {
+	_, r := newTestRedis(t)
+	options.Stream = "events"
+	options.Group = "workers"
+	if options.Consumer == "" {
+		options.Consumer = "worker-1"
+	}
+	options.StartID = "0"
+	consumer := r.NewConsumer(options, handler)
+	require.NoError(t, r.client.XGroupCreateMkStream(context.Background(), "events", "workers", "0").Err())
+	return r, consumer
+}
+
+func addMessages(t *testing.T, r Redis, count int) []string {
+	ids := make([]string, count)
+	for i := range ids {
+		id, err := r.StreamAdd(context.Background(), "events", map[string]interface{}{"n": i}, 0)
+		require.NoError(t, err)
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestConsumer_deliveries(t *testing.T) {
+	ctx := context.Background()
+	r, consumer := newTestConsumer(t, ConsumerOptions{}, nil)
+	ids := addMessages(t, r, 3)
+
+	_, err := r.client.XReadGroup(ctx, &redisClient.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "worker-1",
+		Streams:  []string{"events", ">"},
+	}).Result()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, r.client.XClaim(ctx, &redisClient.XClaimArgs{
+			Stream:   "events",
+			Group:    "workers",
+			Consumer: "worker-1",
+			Messages: []string{ids[2]},
+		}).Err())
+	}
+
+	// The pending entry in between must not push the last one out.
+	deliveries, err := consumer.deliveries(ctx, []redisClient.XMessage{{ID: ids[0]}, {ID: ids[2]}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{ids[0]: 1, ids[2]: 4}, deliveries)
+}
+
+func TestConsumer_handle(t *testing.T) {
+	ctx := context.Background()
+	var handled []string
+	r, consumer := newTestConsumer(t, ConsumerOptions{MaxDeliveries: 2}, func(ctx context.Context, message StreamMessage) error {
+		handled = append(handled, message.ID)
+		if message.Values["n"] == "1" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	ids := addMessages(t, r, 3)
+	_, err := consumer.read(ctx, ">")
+	require.NoError(t, err)
+
+	assert.Equal(t, ids, handled)
+	pending, err := r.client.XPending(ctx, "events", "workers").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending.Count, "the failed message stays pending")
+
+	err = consumer.handle(ctx, []redisClient.XMessage{{ID: ids[1], Values: map[string]interface{}{"n": "1"}}}, map[string]int64{ids[1]: 3})
+	require.NoError(t, err)
+	assert.Len(t, handled, 3, "an exhausted message is not handled")
+
+	dead, err := r.client.XRange(ctx, "events:dead", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, ids[1], dead[0].Values["dead_letter_id"])
+	assert.Equal(t, "3", dead[0].Values["dead_letter_deliveries"])
+	pending, err = r.client.XPending(ctx, "events", "workers").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestConsumer_autoClaim(t *testing.T) {
+	ctx := context.Background()
+	r, consumer := newTestConsumer(t, ConsumerOptions{Consumer: "worker-2", ClaimIdle: time.Millisecond}, nil)
+	ids := addMessages(t, r, 2)
+	_, err := r.client.XReadGroup(ctx, &redisClient.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "worker-1",
+		Streams:  []string{"events", ">"},
+	}).Result()
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	messages, next, err := consumer.autoClaim(ctx, "0-0")
+	assert.NoError(t, err)
+	assert.Equal(t, "0-0", next)
+	require.Len(t, messages, 2)
+	assert.Equal(t, ids[0], messages[0].ID)
+	assert.Equal(t, map[string]interface{}{"n": "0"}, messages[0].Values)
+}
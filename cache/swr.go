@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshOptions = errors.New("soft expiration must be positive and at most the hard expiration")
+)
+
+const (
+	refreshLockPrefix     = "refresh:"
+	defaultRefreshTimeout = 30 * time.Second
+)
+
+type RefreshOptions struct {
+	// SoftExpiration is how long a value is fresh. Reads after it return the
+	// stale value and refresh it in the background.
+	SoftExpiration time.Duration
+	// HardExpiration is the TTL of the value in Redis, reads after it block
+	// on the loader. It can't be shorter than SoftExpiration.
+	HardExpiration time.Duration
+	// RefreshTimeout bounds a background refresh, it defaults to 30 seconds.
+	RefreshTimeout time.Duration
+	// OnError is called with the errors GetOrRefresh can't return, those of
+	// a background refresh or of a loaded value that couldn't be stored.
+	OnError func(key string, err error)
+}
+
+// GetOrRefresh works like GetOrLoad in stale-while-revalidate mode. A stale
+// value is decoded into dst right away while a single instance, holding a
+// Redis lock, reloads it in the background. The background loader runs
+// with its own context bounded by RefreshTimeout. It returns
+// ErrInvalidRefreshOptions when SoftExpiration isn't positive or exceeds
+// HardExpiration.
+func (r *Redis) GetOrRefresh(ctx context.Context, key string, dst interface{}, options RefreshOptions, loader Loader) error {
+	if options.SoftExpiration <= 0 || options.HardExpiration < options.SoftExpiration {
+		return ErrInvalidRefreshOptions
+	}
+	if options.RefreshTimeout <= 0 {
+		options.RefreshTimeout = defaultRefreshTimeout
+	}
+
+	values, err := r.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	staleAt, value, ok := decodeEnvelope(values[key])
+	if !ok {
+		loaded, err, _ := r.group.Do(r.key(key), func() (interface{}, error) {
+			data, err := r.loadRefresh(ctx, loader)
+			if err != nil {
+				return nil, err
+			}
+			// A value that can't be cached is still returned, the caller
+			// has no stale copy to fall back to, and OnError reports why.
+			options.observe(key, r.storeRefresh(ctx, key, data, options))
+			return data, nil
+		})
+		if err != nil {
+			return err
+		}
+		value = loaded.(string)
+	} else if !time.Now().Before(staleAt) {
+		r.refreshInBackground(ctx, key, options, loader)
+	}
+	return r.codec.Unmarshal([]byte(value), dst)
+}
+
+func (r *Redis) refreshInBackground(ctx context.Context, key string, options RefreshOptions, loader Loader) {
+	lock, err := r.Obtain(ctx, refreshLockPrefix+key, options.RefreshTimeout, LockOptions{})
+	if err != nil {
+		// Another instance is refreshing the value.
+		if !errors.Is(err, ErrNotObtained) {
+			options.observe(key, err)
+		}
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), options.RefreshTimeout)
+		defer cancel()
+		defer lock.Release(ctx)
+
+		data, err := r.loadRefresh(ctx, loader)
+		if err == nil {
+			err = r.storeRefresh(ctx, key, data, options)
+		}
+		options.observe(key, err)
+	}()
+}
+
+// loadRefresh runs loader and returns the encoded value.
+func (r *Redis) loadRefresh(ctx context.Context, loader Loader) (string, error) {
+	result, err := loader(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := r.codec.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// storeRefresh stores the encoded value of key with its soft expiry.
+func (r *Redis) storeRefresh(ctx context.Context, key, data string, options RefreshOptions) error {
+	staleAt := time.Now().Add(options.SoftExpiration)
+	return r.StoreWithExpire(ctx, map[string]string{
+		key: encodeEnvelope(staleAt, data),
+	}, options.HardExpiration)
+}
+
+func (o RefreshOptions) observe(key string, err error) {
+	if err != nil && o.OnError != nil {
+		o.OnError(key, err)
+	}
+}
+
+// encodeEnvelope prefixes value with the time it goes stale in unix
+// milliseconds.
+func encodeEnvelope(staleAt time.Time, value string) string {
+	return strconv.FormatInt(staleAt.UnixNano()/int64(time.Millisecond), 10) + ":" + value
+}
+
+func decodeEnvelope(data string) (time.Time, string, bool) {
+	i := strings.IndexByte(data, ':')
+	if i < 0 {
+		return time.Time{}, "", false
+	}
+	millis, err := strconv.ParseInt(data[:i], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), data[i+1:], true
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	staleAt := time.Unix(1700000000, 123*int64(time.Millisecond))
+	data := encodeEnvelope(staleAt, `{"a":"b:c"}`)
+	assert.Equal(t, `1700000000123:{"a":"b:c"}`, data)
+
+	gotStaleAt, value, ok := decodeEnvelope(data)
+	assert.True(t, ok)
+	assert.True(t, staleAt.Equal(gotStaleAt))
+	assert.Equal(t, `{"a":"b:c"}`, value)
+
+	for _, data := range []string{"", "value", "soon:value"} {
+		_, _, ok := decodeEnvelope(data)
+		assert.False(t, ok, data)
+	}
+}
+
+func TestRedis_GetOrRefresh(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	options := RefreshOptions{SoftExpiration: time.Minute, HardExpiration: time.Hour}
+	calls := 0
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return "value", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		var got string
+		require.NoError(t, r.GetOrRefresh(ctx, "key", &got, options, loader))
+		assert.Equal(t, "value", got)
+	}
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, time.Hour, server.TTL("key"))
+}
+
+func TestRedis_GetOrRefresh_StoreError(t *testing.T) {
+	_, r := newTestRedis(t)
+	r = r.WithCompression(Compression{Algorithm: CompressionAlgorithm(99)})
+	var observed error
+	options := RefreshOptions{
+		SoftExpiration: time.Minute,
+		HardExpiration: time.Hour,
+		OnError:        func(key string, err error) { observed = err },
+	}
+
+	var got string
+	err := r.GetOrRefresh(context.Background(), "key", &got, options, func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "value", got)
+	assert.ErrorIs(t, observed, ErrStore)
+}
+
+func TestRedis_GetOrRefresh_Stale(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, server.Set("key", encodeEnvelope(time.Now().Add(-time.Second), `"stale"`)))
+
+	var got string
+	err := r.GetOrRefresh(ctx, "key", &got, RefreshOptions{SoftExpiration: time.Minute, HardExpiration: time.Hour}, func(ctx context.Context) (interface{}, error) {
+		return "fresh", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "stale", got)
+
+	assert.Eventually(t, func() bool {
+		data, _ := server.Get("key")
+		_, value, _ := decodeEnvelope(data)
+		return value == `"fresh"` && !server.Exists(refreshLockPrefix+"key")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRedis_GetOrRefresh_StaleError(t *testing.T) {
+	server, r := newTestRedis(t)
+	require.NoError(t, server.Set("key", encodeEnvelope(time.Now().Add(-time.Second), `"stale"`)))
+	failure := errors.New("source unavailable")
+	observed := make(chan error, 1)
+	options := RefreshOptions{
+		SoftExpiration: time.Minute,
+		HardExpiration: time.Hour,
+		OnError:        func(key string, err error) { observed <- err },
+	}
+
+	var got string
+	err := r.GetOrRefresh(context.Background(), "key", &got, options, func(ctx context.Context) (interface{}, error) {
+		return nil, failure
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "stale", got)
+
+	select {
+	case err := <-observed:
+		assert.ErrorIs(t, err, failure)
+	case <-time.After(time.Second):
+		t.Fatal("refresh error not observed")
+	}
+}
+
+func TestRedis_GetOrRefresh_InvalidOptions(t *testing.T) {
+	_, r := newTestRedis(t)
+	tests := []struct {
+		name    string
+		options RefreshOptions
+	}{
+		{"Without soft expiration", RefreshOptions{HardExpiration: time.Hour}},
+		{"Negative soft expiration", RefreshOptions{SoftExpiration: -time.Minute, HardExpiration: time.Hour}},
+		{"Without hard expiration", RefreshOptions{SoftExpiration: time.Minute}},
+		{"Hard before soft", RefreshOptions{SoftExpiration: time.Hour, HardExpiration: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			err := r.GetOrRefresh(context.Background(), "key", &got, tt.options, func(ctx context.Context) (interface{}, error) {
+				t.Fatal("loader called")
+				return nil, nil
+			})
+			assert.ErrorIs(t, err, ErrInvalidRefreshOptions)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+const tagPrefix = "tag:"
+
+var (
+	ErrInvalidate = errors.New("unable to invalidate")
+)
+
+var (
+	// tagScript adds ARGV[2..] to the tag set KEYS[1] and makes sure the set
+	// lives at least ARGV[1] milliseconds, zero meaning forever.
+	tagScript = redisClient.NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+redis.call("SADD", KEYS[1], unpack(ARGV, 2))
+local expiration = tonumber(ARGV[1])
+if expiration == 0 then
+	redis.call("PERSIST", KEYS[1])
+elseif existed == 0 or (ttl >= 0 and ttl < expiration) then
+	redis.call("PEXPIRE", KEYS[1], expiration)
+end
+return 1
+`)
+	// invalidateScript deletes every member of the tag sets in KEYS and the
+	// sets themselves. The members are not declared as keys, so this does
+	// not work on Redis Cluster.
+	invalidateScript = redisClient.NewScript(`
+local deleted = 0
+for _, tag in ipairs(KEYS) do
+	local members = redis.call("SMEMBERS", tag)
+	for i = 1, #members, 1000 do
+		deleted = deleted + redis.call("DEL", unpack(members, i, math.min(i + 999, #members)))
+	end
+	redis.call("DEL", tag)
+end
+return deleted
+`)
+)
+
+type tagEntry struct {
+	keys       []string
+	expiration time.Duration
+}
+
+// StoreWithTags works like StoreWithExpire and attaches tags to every key,
+// so they can be deleted together with InvalidateTags.
+func (r *Redis) StoreWithTags(ctx context.Context, values map[string]string, duration time.Duration, tags ...string) error {
+	items := make(map[string]Item, len(values))
+	for key, value := range values {
+		items[key] = Item{Value: value, Expiration: duration, Tags: tags}
+	}
+	return r.StoreItems(ctx, items)
+}
+
+// InvalidateTags atomically deletes every key carrying one of tags and
+// returns the number of deleted keys.
+func (r *Redis) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = r.key(tagPrefix + tag)
+	}
+
+	deleted, err := invalidateScript.Run(ctx, r.client, keys).Int64()
+	if err != nil {
+		return 0, newError("invalidate", ErrInvalidate, err, tags...)
+	}
+	return deleted, nil
+}
+
+// queueTags adds the tag index updates of items to pipe and returns the
+// keys of every queued command. A tag set expires with the longest lived
+// key it holds.
+func (r *Redis) queueTags(ctx context.Context, pipe redisClient.Pipeliner, keys []string, items map[string]Item) [][]string {
+	entries := map[string]*tagEntry{}
+	for _, key := range keys {
+		item := items[key]
+		for _, tag := range item.Tags {
+			entry, ok := entries[tag]
+			if !ok {
+				entry = &tagEntry{expiration: item.Expiration}
+				entries[tag] = entry
+			}
+			entry.keys = append(entry.keys, key)
+			if item.Expiration == 0 || (entry.expiration != 0 && item.Expiration > entry.expiration) {
+				entry.expiration = item.Expiration
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(entries))
+	for tag := range entries {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	queued := make([][]string, len(tags))
+	for i, tag := range tags {
+		entry := entries[tag]
+		args := make([]interface{}, 0, len(entry.keys)+1)
+		args = append(args, entry.expiration.Milliseconds())
+		for _, key := range entry.keys {
+			args = append(args, r.key(key))
+		}
+		tagScript.Eval(ctx, pipe, []string{r.key(tagPrefix + tag)}, args...)
+		queued[i] = entry.keys
+	}
+	return queued
+}
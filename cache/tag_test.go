@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_InvalidateTags(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"a": "1", "b": "2"}, time.Minute, "products"))
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"c": "3"}, time.Minute, "products", "featured"))
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"d": "4"}, time.Minute, "featured"))
+	require.NoError(t, r.Store(ctx, map[string]string{"untagged": "5"}))
+
+	deleted, err := r.InvalidateTags(ctx, "products")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	for _, key := range []string{"a", "b", "c", tagPrefix + "products"} {
+		assert.False(t, server.Exists(key), key)
+	}
+	assert.True(t, server.Exists("d"))
+	assert.True(t, server.Exists("untagged"))
+
+	// c was deleted with the first tag, only d is left to count.
+	deleted, err = r.InvalidateTags(ctx, "featured", "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, []string{"untagged"}, server.Keys())
+
+	deleted, err = r.InvalidateTags(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestRedis_InvalidateTags_SharedAcrossKeys(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"a": "1"}, time.Minute, "one", "two"))
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"b": "2"}, time.Minute, "two"))
+
+	deleted, err := r.InvalidateTags(ctx, "one", "two")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.Empty(t, server.Keys())
+}
+
+func TestRedis_InvalidateTags_ExpiredMember(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"short": "1"}, time.Second, "tag"))
+	require.NoError(t, r.StoreWithTags(ctx, map[string]string{"long": "2"}, time.Hour, "tag"))
+	server.FastForward(2 * time.Second)
+	require.False(t, server.Exists("short"))
+
+	deleted, err := r.InvalidateTags(ctx, "tag")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Empty(t, server.Keys())
+}
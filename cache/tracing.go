@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracingObserver struct {
+	tracer trace.Tracer
+}
+
+// NewTracingObserver returns an Observer recording an OpenTelemetry span
+// for every command, as a child of the span in the command context.
+func NewTracingObserver(tracer trace.Tracer) Observer {
+	return tracingObserver{tracer: tracer}
+}
+
+func (o tracingObserver) Observe(ctx context.Context, event Event) {
+	_, span := o.tracer.Start(ctx, "cache."+event.Operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(event.Start),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", event.Operation),
+			attribute.String("cache.prefix", event.Prefix),
+			attribute.Int("cache.hits", event.Hits),
+			attribute.Int("cache.misses", event.Misses),
+		),
+	)
+	if event.Err != nil {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+	span.End(trace.WithTimestamp(event.Start.Add(event.Duration)))
+}
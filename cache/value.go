@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	redisClient "github.com/go-redis/redis/v8"
+)
+
+// WithCodec returns a copy of r that encodes typed values with codec.
+func (r Redis) WithCodec(codec Codec) Redis {
+	r.codec = codec
+	return r
+}
+
+// SetValue encodes value with the codec of r and stores it under key.
+func (r *Redis) SetValue(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return r.SetValues(ctx, map[string]interface{}{key: value}, expiration)
+}
+
+// SetValues encodes and stores every value atomically, see StoreItems.
+func (r *Redis) SetValues(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	items := make(map[string]Item, len(values))
+	for key, value := range values {
+		data, err := r.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		items[key] = Item{Value: string(data), Expiration: expiration}
+	}
+	return r.StoreItems(ctx, items)
+}
+
+// GetValue decodes the value stored under key into dst. It returns ErrMiss
+// when the key does not exist, which is distinct from an empty value.
+func (r *Redis) GetValue(ctx context.Context, key string, dst interface{}) error {
+	value, err := r.client.Get(ctx, r.key(key)).Result()
+	if err == redisClient.Nil || r.failOpen(err) {
+		return newError("get", ErrMiss, nil, key)
+	}
+	if err != nil {
+		return newError("get", ErrRetrieve, err, key)
+	}
+	if value, err = decompress(value); err != nil {
+		return newError("get", ErrRetrieve, err, key)
+	}
+	return r.codec.Unmarshal([]byte(value), dst)
+}
+
+// Lookup works like Get but leaves missing keys out of the result instead
+// of reporting them as empty strings.
+func (r *Redis) Lookup(ctx context.Context, keys ...string) (map[string]string, error) {
+	result, err := r.client.MGet(ctx, r.keys(keys)...).Result()
+	if r.failOpen(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, newError("get", ErrRetrieve, err, keys...)
+	}
+
+	data := map[string]string{}
+	for i, key := range keys {
+		s, ok := result[i].(string)
+		if !ok {
+			continue
+		}
+		if data[key], err = decompress(s); err != nil {
+			return nil, newError("get", ErrRetrieve, err, key)
+		}
+	}
+	return data, nil
+}
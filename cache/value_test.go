@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_GetValue(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, r.SetValues(ctx, map[string]interface{}{
+		"empty": "",
+		"zero":  0,
+	}, 0))
+	require.NoError(t, r.SetValue(ctx, "expired", "value", time.Minute))
+	server.FastForward(time.Minute)
+
+	var empty string
+	require.NoError(t, r.GetValue(ctx, "empty", &empty))
+	assert.Equal(t, "", empty)
+
+	zero := -1
+	require.NoError(t, r.GetValue(ctx, "zero", &zero))
+	assert.Equal(t, 0, zero)
+
+	for _, key := range []string{"missing", "expired"} {
+		var got string
+		err := r.GetValue(ctx, key, &got)
+		assert.ErrorIs(t, err, ErrMiss, key)
+		assert.NotErrorIs(t, err, ErrRetrieve, key)
+	}
+}
+
+func TestRedis_Lookup(t *testing.T) {
+	server, r := newTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, r.Store(ctx, map[string]string{"empty": "", "zero": "0"}))
+	require.NoError(t, r.StoreWithExpire(ctx, map[string]string{"expired": "value"}, time.Minute))
+	server.FastForward(time.Minute)
+
+	data, err := r.Lookup(ctx, "empty", "zero", "missing", "expired")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"empty": "", "zero": "0"}, data)
+
+	// Get reports the same keys as empty strings instead.
+	data, err = r.Get(ctx, "empty", "missing", "expired")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"empty": "", "missing": "", "expired": ""}, data)
+}
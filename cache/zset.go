@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+
+	redisClient "github.com/go-redis/redis/v8"
+	"github.com/rideziro/go-storage/storage"
+	"github.com/rideziro/go-storage/storage/mysql/scopes"
+)
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSetAdd adds members to the sorted set stored under key, updating
+// the score of existing ones.
+func (r *Redis) SortedSetAdd(ctx context.Context, key string, members ...ScoredMember) error {
+	values := make([]*redisClient.Z, len(members))
+	for i, member := range members {
+		values[i] = &redisClient.Z{Score: member.Score, Member: member.Member}
+	}
+	if err := r.client.ZAdd(ctx, r.key(key), values...).Err(); err != nil {
+		return newError("zadd", ErrStore, err, key)
+	}
+	return nil
+}
+
+// SortedSetIncrBy atomically adds incr to the score of member and returns
+// the new score.
+func (r *Redis) SortedSetIncrBy(ctx context.Context, key, member string, incr float64) (float64, error) {
+	score, err := r.client.ZIncrBy(ctx, r.key(key), incr, member).Result()
+	if err != nil {
+		return 0, newError("zincrby", ErrStore, err, key)
+	}
+	return score, nil
+}
+
+func (r *Redis) SortedSetRemove(ctx context.Context, key string, members ...string) error {
+	values := make([]interface{}, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+	if err := r.client.ZRem(ctx, r.key(key), values...).Err(); err != nil {
+		return newError("zrem", ErrDelete, err, key)
+	}
+	return nil
+}
+
+// SortedSetRangeByRank returns a page of members ordered by score,
+// highest first when reverse is set, for example a leaderboard page. Like
+// scopes.Paginate the paginator is normalized and moved to the next page,
+// a nil paginator returns every member.
+func (r *Redis) SortedSetRangeByRank(ctx context.Context, key string, paginator *storage.Paginator, reverse bool) ([]ScoredMember, error) {
+	offset, count := pageRange(paginator)
+	stop := offset + count - 1
+	if count < 0 {
+		stop = -1
+	}
+
+	var (
+		values []redisClient.Z
+		err    error
+	)
+	if reverse {
+		values, err = r.client.ZRevRangeWithScores(ctx, r.key(key), offset, stop).Result()
+	} else {
+		values, err = r.client.ZRangeWithScores(ctx, r.key(key), offset, stop).Result()
+	}
+	if err != nil {
+		return nil, newError("zrange", ErrRetrieve, err, key)
+	}
+	return scoredMembers(values), nil
+}
+
+// SortedSetRangeByScore returns a page of members with a score between min
+// and max, which accept the ZRANGEBYSCORE syntax such as "-inf" or "(10".
+// The paginator is handled like in SortedSetRangeByRank.
+func (r *Redis) SortedSetRangeByScore(ctx context.Context, key, min, max string, paginator *storage.Paginator, reverse bool) ([]ScoredMember, error) {
+	offset, count := pageRange(paginator)
+	by := &redisClient.ZRangeBy{
+		Min:    min,
+		Max:    max,
+		Offset: offset,
+		Count:  count,
+	}
+
+	var (
+		values []redisClient.Z
+		err    error
+	)
+	if reverse {
+		values, err = r.client.ZRevRangeByScoreWithScores(ctx, r.key(key), by).Result()
+	} else {
+		values, err = r.client.ZRangeByScoreWithScores(ctx, r.key(key), by).Result()
+	}
+	if err != nil {
+		return nil, newError("zrangebyscore", ErrRetrieve, err, key)
+	}
+	return scoredMembers(values), nil
+}
+
+// SortedSetRank returns the zero based rank of member, counted from the
+// highest score when reverse is set. It returns ErrMiss when member is not
+// in the set.
+func (r *Redis) SortedSetRank(ctx context.Context, key, member string, reverse bool) (int64, error) {
+	var cmd *redisClient.IntCmd
+	if reverse {
+		cmd = r.client.ZRevRank(ctx, r.key(key), member)
+	} else {
+		cmd = r.client.ZRank(ctx, r.key(key), member)
+	}
+
+	rank, err := cmd.Result()
+	if err == redisClient.Nil {
+		return 0, newError("zrank", ErrMiss, nil, key)
+	}
+	if err != nil {
+		return 0, newError("zrank", ErrRetrieve, err, key)
+	}
+	return rank, nil
+}
+
+// pageRange returns the offset and count of the page described by
+// paginator and moves it to the next page. The size is capped at
+// scopes.MaxPaginationSize.
+func pageRange(paginator *storage.Paginator) (int64, int64) {
+	if paginator == nil {
+		return 0, -1
+	}
+	switch {
+	case paginator.Size > scopes.MaxPaginationSize:
+		paginator.Size = scopes.MaxPaginationSize
+	case paginator.Size <= 0:
+		paginator.Size = storage.PaginatorDefaultSize
+	}
+	if paginator.Page <= 0 {
+		paginator.Page = 1
+	}
+
+	offset := int64((paginator.Page - 1) * paginator.Size)
+	count := int64(paginator.Size)
+	paginator.IncreasePage()
+	return offset, count
+}
+
+func scoredMembers(values []redisClient.Z) []ScoredMember {
+	members := make([]ScoredMember, len(values))
+	for i, value := range values {
+		member, _ := value.Member.(string)
+		members[i] = ScoredMember{Member: member, Score: value.Score}
+	}
+	return members
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/rideziro/go-storage/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		paginator *storage.Paginator
+		offset    int64
+		count     int64
+		next      *storage.Paginator
+	}{
+		{"Nil", nil, 0, -1, nil},
+		{"Default", &storage.Paginator{}, 0, 15, &storage.Paginator{Page: 2, Size: 15}},
+		{"Page", &storage.Paginator{Page: 3, Size: 10}, 20, 10, &storage.Paginator{Page: 4, Size: 10}},
+		{"Capped", &storage.Paginator{Page: 2, Size: 1000}, 50, 50, &storage.Paginator{Page: 3, Size: 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, count := pageRange(tt.paginator)
+			assert.Equal(t, tt.offset, offset)
+			assert.Equal(t, tt.count, count)
+			assert.Equal(t, tt.next, tt.paginator)
+		})
+	}
+}
@@ -2,15 +2,38 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/rideziro/go-storage/cache"
 	"github.com/rideziro/go-storage/storage"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"time"
+)
+
+const (
+	migrationLockKey = "migration:lock"
+	migrationLockTTL = 30 * time.Second
 )
 
 var MigrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Relational database migration",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if viper.GetBool("MIGRATION_LOCK") {
+			redis, err := cache.NewDefaultRedisE()
+			if err != nil {
+				return fmt.Errorf("migration lock: %w", err)
+			}
+			lock, err := redis.Obtain(cmd.Context(), migrationLockKey, migrationLockTTL, cache.LockOptions{
+				RetryInterval:    time.Second,
+				MaxRetryInterval: 10 * time.Second,
+				AutoRenew:        true,
+			})
+			if err != nil {
+				return fmt.Errorf("migration lock: %w", err)
+			}
+			defer lock.Release(cmd.Context())
+		}
+
 		migrationPath := viper.GetString("MIGRATION_PATH")
 		migrator, err := storage.NewMigrator(migrationPath)
 		if err != nil {
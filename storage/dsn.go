@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrInvalidCA = errors.New("no certificate found in CA file")
+)
+
+// config returns the driver configuration of node, Params override the
+// default charset=utf8mb4 and parseTime=True.
+func (d *Database) config(node Node) *mysqlDriver.Config {
+	port := node.Port
+	if port == "" {
+		port = d.Port
+	}
+
+	config := mysqlDriver.NewConfig()
+	config.User = d.Username
+	config.Passwd = d.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(node.Host, port)
+	config.DBName = d.DatabaseName
+	config.TLSConfig = d.tlsName()
+	config.Params = map[string]string{
+		"charset":   "utf8mb4",
+		"parseTime": "True",
+	}
+	for key, value := range d.Params {
+		config.Params[key] = value
+	}
+	return config
+}
+
+// RegisterTLS registers the custom TLS configuration built from TLSCA,
+// TLSCert and TLSKey with the driver. New calls it, it is only needed to
+// open a DSN by other means.
+func (d *Database) RegisterTLS() error {
+	if !d.customTLS() {
+		return nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: d.TLS == "skip-verify",
+	}
+	if d.TLSCA != "" {
+		pem, err := ioutil.ReadFile(d.TLSCA)
+		if err != nil {
+			return fmt.Errorf("database.RegisterTLS() read CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("database.RegisterTLS() read CA: %w", ErrInvalidCA)
+		}
+	}
+	if d.TLSCert != "" || d.TLSKey != "" {
+		certificate, err := tls.LoadX509KeyPair(d.TLSCert, d.TLSKey)
+		if err != nil {
+			return fmt.Errorf("database.RegisterTLS() load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if err := mysqlDriver.RegisterTLSConfig(d.tlsName(), config); err != nil {
+		return fmt.Errorf("database.RegisterTLS(): %w", err)
+	}
+	return nil
+}
+
+func (d *Database) customTLS() bool {
+	return d.TLSCA != "" || d.TLSCert != "" || d.TLSKey != ""
+}
+
+// tlsName returns the tls parameter of the DSN. A custom configuration is
+// named after its files so databases with different certificates don't
+// replace each other's.
+func (d *Database) tlsName() string {
+	if !d.customTLS() {
+		return d.TLS
+	}
+	sum := sha1.Sum([]byte(d.TLSCA + "\x00" + d.TLSCert + "\x00" + d.TLSKey))
+	return "storage-" + hex.EncodeToString(sum[:6])
+}
+
+// migrateTLSName returns the tls parameter of the golang-migrate DSN.
+// golang-migrate builds a custom configuration itself but can't without a
+// CA, the server is then verified with the system roots and the client
+// certificate is not presented. NewMigrator doesn't have this limitation.
+func (d *Database) migrateTLSName() string {
+	if !d.customTLS() || d.TLSCA != "" {
+		return d.tlsName()
+	}
+	if d.TLS == "skip-verify" {
+		return "skip-verify"
+	}
+	return "true"
+}
+
+// migrateParams returns the parameters golang-migrate needs to build the
+// custom TLS configuration itself.
+func (d *Database) migrateParams() string {
+	if !d.customTLS() || d.TLSCA == "" {
+		return ""
+	}
+
+	params := url.Values{}
+	params.Set("x-tls-ca", d.TLSCA)
+	if d.TLSCert != "" {
+		params.Set("x-tls-cert", d.TLSCert)
+	}
+	if d.TLSKey != "" {
+		params.Set("x-tls-key", d.TLSKey)
+	}
+	if d.TLS == "skip-verify" {
+		params.Set("x-tls-insecure-skip-verify", "true")
+	}
+	return params.Encode()
+}
+
+// parseParams parses DB_PARAMS, a query string such as
+// "loc=Local&timeout=5s", invalid pairs are skipped.
+func parseParams(query string) map[string]string {
+	values, _ := url.ParseQuery(query)
+	if len(values) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(values))
+	for key := range values {
+		params[key] = values.Get(key)
+	}
+	return params
+}
@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"database/sql"
 	"fmt"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
@@ -31,16 +34,35 @@ func (m *Migrator) Down() error {
 	return nil
 }
 
+// NewMigrator opens the primary itself rather than through DSNMigrate, so
+// the migrations use the same TLS configuration as New, client certificate
+// included, whether or not TLSCA is set.
 func NewMigrator(migrationPath string) (*Migrator, error) {
 	database := DefaultDatabase()
-	migrationDSN := database.DSNMigrate()
-	migrator, err := migrate.New(
-		migrationPath,
-		migrationDSN,
-	)
-
+	if err := database.RegisterTLS(); err != nil {
+		return nil, fmt.Errorf("migration() creating instance: %v", err)
+	}
+	db, err := sql.Open("mysql", database.migrateConfig().FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("migration() creating instance: %v", err)
+	}
+	driver, err := mysql.WithInstance(db, &mysql.Config{})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migration() creating instance: %v", err)
+	}
+	migrator, err := migrate.NewWithDatabaseInstance(migrationPath, "mysql", driver)
 	if err != nil {
+		_ = driver.Close()
 		return nil, fmt.Errorf("migration() creating instance: %v", err)
 	}
 	return &Migrator{migrator}, nil
 }
+
+// migrateConfig returns the driver configuration of the primary for
+// golang-migrate, which needs multiple statements per query.
+func (d *Database) migrateConfig() *mysqlDriver.Config {
+	config := d.config(Node{Host: d.Host})
+	config.MultiStatements = true
+	return config
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// track applies the pool settings of d to pool and keeps it to be closed by
+// Close. Pools that are not a *sql.DB, such as transactions, are ignored.
+func (d *Database) track(pool gorm.ConnPool) {
+	db, ok := pool.(*sql.DB)
+	if !ok {
+		return
+	}
+	for _, tracked := range d.pools {
+		if tracked == db {
+			return
+		}
+	}
+
+	if d.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(d.MaxOpenConns)
+	}
+	if d.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(d.MaxIdleConns)
+	}
+	if d.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(d.ConnMaxLifetime)
+	}
+	if d.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(d.ConnMaxIdleTime)
+	}
+	d.pools = append(d.pools, db)
+}
+
+// Close closes the connections to the primary and the replicas opened by
+// New, the databases it returned can't be used anymore.
+func (d *Database) Close() error {
+	var closeErr error
+	for _, pool := range d.pools {
+		if err := pool.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("database.Close(): %w", err)
+		}
+	}
+	d.pools = nil
+	return closeErr
+}
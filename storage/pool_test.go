@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabase_track(t *testing.T) {
+	database := Database{MaxOpenConns: 20}
+	pool, err := sql.Open("mysql", "user:secret@(localhost:3306)/app")
+	assert.NoError(t, err)
+
+	database.track(pool)
+	database.track(pool)
+	assert.Len(t, database.pools, 1)
+	assert.Equal(t, 20, pool.Stats().MaxOpenConnections)
+
+	assert.NoError(t, database.Close())
+	assert.Empty(t, database.pools)
+	assert.EqualError(t, pool.Ping(), "sql: database is closed")
+}
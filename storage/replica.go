@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type ReplicaPolicy string
+
+const (
+	// RandomReplica picks a replica at random, the default.
+	RandomReplica ReplicaPolicy = "random"
+	// RoundRobinReplica picks the replicas in turn.
+	RoundRobinReplica ReplicaPolicy = "round-robin"
+	// WeightedReplica picks a replica at random in proportion to its
+	// weight.
+	WeightedReplica ReplicaPolicy = "weighted"
+)
+
+// Node is a database server, its port defaults to Database.Port and its
+// weight, only used by WeightedReplica, to 1.
+type Node struct {
+	Host   string
+	Port   string
+	Weight int
+}
+
+// Resolver routes the queries on Tables, models or table names, to their own
+// sources and replicas. Without Sources writes go to Database.Host, without
+// Replicas reads go to the sources.
+type Resolver struct {
+	Sources  []Node
+	Replicas []Node
+	Policy   ReplicaPolicy
+	Tables   []interface{}
+}
+
+// ParseNodes parses a comma separated list of host[:port] entries, such as
+// "replica-1:3306,replica-2". weights is an optional comma separated list of
+// positive weights in the same order.
+func ParseNodes(hosts, weights string) ([]Node, error) {
+	var nodes []Node
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		node := Node{Host: host}
+		if i := strings.LastIndex(host, ":"); i > 0 {
+			node.Host, node.Port = host[:i], host[i+1:]
+		}
+		nodes = append(nodes, node)
+	}
+
+	if strings.TrimSpace(weights) == "" {
+		return nodes, nil
+	}
+	values := strings.Split(weights, ",")
+	if len(values) != len(nodes) {
+		return nil, fmt.Errorf("got %d weights for %d hosts", len(values), len(nodes))
+	}
+	for i, value := range values {
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q: %w", value, err)
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("invalid weight %q: must be positive", value)
+		}
+		nodes[i].Weight = weight
+	}
+	return nodes, nil
+}
+
+// replicas returns ReplicaHost followed by Replicas.
+func (d *Database) replicas() []Node {
+	var replicas []Node
+	if d.ReplicaHost != "" {
+		replicas = append(replicas, Node{Host: d.ReplicaHost})
+	}
+	return append(replicas, d.Replicas...)
+}
+
+// resolver returns the dbresolver plugin routing reads to the replicas and
+// the tables of Resolvers to their nodes, nil when there is nothing to route.
+func (d *Database) resolver() (*dbresolver.DBResolver, error) {
+	var resolver *dbresolver.DBResolver
+	register := func(config dbresolver.Config, tables ...interface{}) {
+		if resolver == nil {
+			resolver = dbresolver.Register(config, tables...)
+		} else {
+			resolver.Register(config, tables...)
+		}
+	}
+
+	if replicas := d.replicas(); len(replicas) > 0 {
+		policy, err := newReplicaPolicy(d.ReplicaPolicy, replicas)
+		if err != nil {
+			return nil, err
+		}
+		register(dbresolver.Config{
+			Replicas: d.dialectors(replicas),
+			Policy:   policy,
+		})
+	}
+
+	for _, r := range d.Resolvers {
+		policy, err := newReplicaPolicy(r.Policy, r.Replicas)
+		if err != nil {
+			return nil, err
+		}
+		register(dbresolver.Config{
+			Sources:  d.dialectors(r.Sources),
+			Replicas: d.dialectors(r.Replicas),
+			Policy:   policy,
+		}, r.Tables...)
+	}
+	return resolver, nil
+}
+
+func (d *Database) dialectors(nodes []Node) []gorm.Dialector {
+	var dialectors []gorm.Dialector
+	for _, node := range nodes {
+		dialectors = append(dialectors, d.dialector(node))
+	}
+	return dialectors
+}
+
+// newReplicaPolicy returns the dbresolver policy of name. dbresolver applies
+// it to the sources as well, the weights only apply to the replicas. A zero
+// weight defaults to 1, a negative one is an error.
+func newReplicaPolicy(name ReplicaPolicy, replicas []Node) (dbresolver.Policy, error) {
+	switch name {
+	case "", RandomReplica:
+		return dbresolver.RandomPolicy{}, nil
+	case RoundRobinReplica:
+		return &roundRobinPolicy{}, nil
+	case WeightedReplica:
+		weights := make([]int, len(replicas))
+		for i, replica := range replicas {
+			switch {
+			case replica.Weight < 0:
+				return nil, fmt.Errorf("invalid weight %d for replica %q", replica.Weight, replica.Host)
+			case replica.Weight == 0:
+				weights[i] = 1
+			default:
+				weights[i] = replica.Weight
+			}
+		}
+		return weightedPolicy{weights: weights}, nil
+	}
+	return nil, fmt.Errorf("unknown replica policy %q", name)
+}
+
+type roundRobinPolicy struct {
+	next uint64
+}
+
+func (p *roundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	i := atomic.AddUint64(&p.next, 1) - 1
+	return connPools[i%uint64(len(connPools))]
+}
+
+// weightedPolicy relies on dbresolver passing the connection pools in the
+// order of the replicas, other pools are picked at random.
+type weightedPolicy struct {
+	weights []int
+}
+
+func (p weightedPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	if len(connPools) != len(p.weights) {
+		return dbresolver.RandomPolicy{}.Resolve(connPools)
+	}
+
+	total := 0
+	for _, weight := range p.weights {
+		total += weight
+	}
+	n := rand.Intn(total)
+	for i, weight := range p.weights {
+		if n < weight {
+			return connPools[i]
+		}
+		n -= weight
+	}
+	return connPools[len(connPools)-1]
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		hosts   string
+		weights string
+		want    []Node
+		wantErr bool
+	}{
+		{
+			"Hosts and ports",
+			"replica-1:3307, replica-2",
+			"",
+			[]Node{{Host: "replica-1", Port: "3307"}, {Host: "replica-2"}},
+			false,
+		},
+		{
+			"Weights",
+			"replica-1,replica-2",
+			"3,1",
+			[]Node{{Host: "replica-1", Weight: 3}, {Host: "replica-2", Weight: 1}},
+			false,
+		},
+		{
+			"Empty",
+			"",
+			"",
+			nil,
+			false,
+		},
+		{
+			"Zero weight",
+			"replica-1,replica-2",
+			"3,0",
+			nil,
+			true,
+		},
+		{
+			"Negative weight",
+			"replica-1,replica-2",
+			"3,-1",
+			nil,
+			true,
+		},
+		{
+			"Missing weight",
+			"replica-1,replica-2",
+			"3",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNodes(tt.hosts, tt.weights)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	pools := []gorm.ConnPool{&sql.DB{}, &sql.DB{}, &sql.DB{}}
+	replicas := []Node{{Weight: 1}, {Weight: 0}, {Weight: 8}}
+
+	policy, err := newReplicaPolicy(RoundRobinReplica, replicas)
+	assert.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		assert.Same(t, pools[i%3], policy.Resolve(pools))
+	}
+
+	policy, err = newReplicaPolicy(WeightedReplica, replicas)
+	assert.NoError(t, err)
+	counts := map[gorm.ConnPool]int{}
+	for i := 0; i < 1000; i++ {
+		counts[policy.Resolve(pools)]++
+	}
+	assert.Greater(t, counts[pools[2]], counts[pools[0]]+counts[pools[1]])
+	assert.NotZero(t, counts[pools[1]], "a missing weight defaults to 1")
+
+	_, err = newReplicaPolicy(WeightedReplica, []Node{{Host: "replica-1", Weight: -1}})
+	assert.Error(t, err)
+
+	_, err = newReplicaPolicy("fastest", replicas)
+	assert.Error(t, err)
+}
+
+func TestDatabase_DSNReplica(t *testing.T) {
+	database := Database{
+		Username:     "user",
+		Password:     "secret",
+		Port:         "3306",
+		DatabaseName: "app",
+		Replicas:     []Node{{Host: "replica-1", Port: "3307"}},
+	}
+	dsn, err := database.DSNReplica()
+	assert.NoError(t, err)
+	assert.Equal(t, "user:secret@tcp(replica-1:3307)/app?charset=utf8mb4&parseTime=True", dsn)
+
+	database.ReplicaHost = "replica-0"
+	dsn, err = database.DSNReplica()
+	assert.NoError(t, err)
+	assert.Equal(t, "user:secret@tcp(replica-0:3306)/app?charset=utf8mb4&parseTime=True", dsn)
+
+	_, err = (&Database{}).DSNReplica()
+	assert.Error(t, err)
+}
+
+func TestDefaultDatabase_ReplicaWeights(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("DB_REPLICA_HOSTS", "replica-1,replica-2:3307")
+	viper.Set("DB_REPLICA_WEIGHTS", "3,x")
+
+	database := DefaultDatabase()
+	assert.Equal(t, []Node{{Host: "replica-1"}, {Host: "replica-2", Port: "3307"}}, database.Replicas)
+
+	_, err := database.New()
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+	assert.Contains(t, err.Error(), "DB_REPLICA_WEIGHTS")
+}
@@ -1,13 +1,15 @@
 package storage
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"gorm.io/plugin/dbresolver"
+	"net/url"
+	"time"
 )
 
 type Database struct {
@@ -19,10 +21,49 @@ type Database struct {
 	Port         string
 	DatabaseName string
 
+	// Replicas serve the reads along with ReplicaHost, picked according to
+	// ReplicaPolicy.
+	Replicas      []Node
+	ReplicaPolicy ReplicaPolicy
+	// Resolvers route some tables to their own nodes.
+	Resolvers []Resolver
+
+	// The pool settings apply to the primary and every replica, zero keeps
+	// the database/sql default. A negative MaxIdleConns keeps no idle
+	// connection.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// TLS is the tls parameter of the driver, such as "true",
+	// "skip-verify" or "preferred". Setting TLSCA, TLSCert or TLSKey, PEM
+	// file paths, enables TLS with a custom CA or client certificate.
+	TLS     string
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+	// Params are extra driver parameters such as loc, timeout or
+	// multiStatements, they override charset=utf8mb4 and parseTime=True.
+	Params map[string]string
+
 	DisableLog bool
+
+	// pools are the connection pools opened by New, closed by Close.
+	pools []*sql.DB
+	// err is the error of a setting DefaultDatabase couldn't parse, New
+	// returns it.
+	err error
 }
 
 func (d *Database) New() (*gorm.DB, error) {
+	if d.err != nil {
+		return nil, fmt.Errorf("database.New() init: %w", d.err)
+	}
+	if err := d.RegisterTLS(); err != nil {
+		return nil, fmt.Errorf("database.New() init: %w", err)
+	}
+
 	config := &gorm.Config{}
 	if d.DisableLog {
 		config.Logger = logger.Discard
@@ -31,12 +72,23 @@ func (d *Database) New() (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("database.New() init: %w", err)
 	}
+	if pool, err := db.DB(); err == nil {
+		d.track(pool)
+	}
 
-	if replica, err := d.DSNReplica(); err == nil {
-		err := db.Use(dbresolver.Register(dbresolver.Config{
-			Replicas: []gorm.Dialector{mysql.Open(replica)},
-		}))
-		if err != nil {
+	resolver, err := d.resolver()
+	if err != nil {
+		_ = d.Close()
+		return nil, fmt.Errorf("database.New() create replica: %w", err)
+	}
+	if resolver != nil {
+		// Called for the pool of every source and replica once opened.
+		_ = resolver.Call(func(pool gorm.ConnPool) error {
+			d.track(pool)
+			return nil
+		})
+		if err := db.Use(resolver); err != nil {
+			_ = d.Close()
 			return nil, fmt.Errorf("database.New() create replica: %w", err)
 		}
 	}
@@ -44,39 +96,41 @@ func (d *Database) New() (*gorm.DB, error) {
 }
 
 func (d *Database) DSN() string {
-	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True",
-		d.Username,
-		d.Password,
-		d.Host,
-		d.Port,
-		d.DatabaseName,
-	)
-	return dsn
+	return d.dsn(Node{Host: d.Host})
 }
 
+// DSNReplica returns the DSN of the first replica.
 func (d *Database) DSNReplica() (string, error) {
-	if d.ReplicaHost == "" {
+	replicas := d.replicas()
+	if len(replicas) == 0 {
 		return "", errors.New("no replica found")
 	}
-	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True",
-		d.Username,
-		d.Password,
-		d.ReplicaHost,
-		d.Port,
-		d.DatabaseName,
-	)
-	return dsn, nil
+	return d.dsn(replicas[0]), nil
 }
 
+func (d *Database) dsn(node Node) string {
+	return d.config(node).FormatDSN()
+}
+
+func (d *Database) dialector(node Node) gorm.Dialector {
+	return mysql.Open(d.dsn(node))
+}
+
+// DSNMigrate returns the DSN of the primary as a golang-migrate URL, with
+// the certificate paths of a custom TLS configuration. Without TLSCA the URL
+// can't carry a client certificate, see migrateTLSName, NewMigrator opens
+// the database with the configuration of RegisterTLS instead. golang-migrate
+// unescapes the credentials, so they are escaped here.
 func (d *Database) DSNMigrate() string {
-	dsn := fmt.Sprintf("%s://%s:%s@(%s:%s)/%s?charset=utf8mb4",
-		d.Driver,
-		d.Username,
-		d.Password,
-		d.Host,
-		d.Port,
-		d.DatabaseName,
-	)
+	config := d.config(Node{Host: d.Host})
+	config.TLSConfig = d.migrateTLSName()
+	config.User = url.QueryEscape(config.User)
+	config.Passwd = url.QueryEscape(config.Passwd)
+
+	dsn := d.Driver + "://" + config.FormatDSN()
+	if params := d.migrateParams(); params != "" {
+		dsn += "&" + params
+	}
 	return dsn
 }
 
@@ -95,5 +149,27 @@ func DefaultDatabase() Database {
 		database.Driver = "mysql"
 	}
 
+	// Invalid weights fail New, the replicas are kept with their default
+	// weight for the DSNs.
+	hosts := viper.GetString("DB_REPLICA_HOSTS")
+	replicas, err := ParseNodes(hosts, viper.GetString("DB_REPLICA_WEIGHTS"))
+	if err != nil {
+		database.err = fmt.Errorf("DB_REPLICA_WEIGHTS: %w", err)
+		replicas, _ = ParseNodes(hosts, "")
+	}
+	database.Replicas = replicas
+	database.ReplicaPolicy = ReplicaPolicy(viper.GetString("DB_REPLICA_POLICY"))
+
+	database.MaxOpenConns = viper.GetInt("DB_MAX_OPEN_CONNS")
+	database.MaxIdleConns = viper.GetInt("DB_MAX_IDLE_CONNS")
+	database.ConnMaxLifetime = viper.GetDuration("DB_CONN_MAX_LIFETIME")
+	database.ConnMaxIdleTime = viper.GetDuration("DB_CONN_MAX_IDLE_TIME")
+
+	database.TLS = viper.GetString("DB_TLS")
+	database.TLSCA = viper.GetString("DB_TLS_CA")
+	database.TLSCert = viper.GetString("DB_TLS_CERT")
+	database.TLSKey = viper.GetString("DB_TLS_KEY")
+	database.Params = parseParams(viper.GetString("DB_PARAMS"))
+
 	return database
 }